@@ -0,0 +1,40 @@
+package clash
+
+import "context"
+
+// Backend is the set of controller operations the TUI relies on. Client
+// talks to a real Clash/Mihomo controller, Mock keeps everything in memory.
+// Every operation gives up as soon as ctx is cancelled.
+type Backend interface {
+	GetProxies(ctx context.Context) (*ProxiesResponse, error)
+	SelectProxy(ctx context.Context, groupName, proxyName string) error
+	TestProxyDelay(ctx context.Context, proxyName string, opts DelayOptions) DelayResult
+	TestGroupDelay(ctx context.Context, groupName string, members []string, opts DelayOptions) (map[string]DelayResult, error)
+
+	GetConnections(ctx context.Context) (*ConnectionsResponse, error)
+	CloseConnection(ctx context.Context, id string) error
+	CloseAllConnections(ctx context.Context) error
+
+	GetConfig(ctx context.Context) (*RuntimeConfig, error)
+	PatchConfig(ctx context.Context, patch map[string]interface{}) error
+
+	GetRules(ctx context.Context) (*RulesResponse, error)
+
+	GetProxyProviders(ctx context.Context) (*ProxyProvidersResponse, error)
+	UpdateProxyProvider(ctx context.Context, name string) error
+	HealthCheckProxyProvider(ctx context.Context, name string) error
+	GetRuleProviders(ctx context.Context) (*RuleProvidersResponse, error)
+	UpdateRuleProvider(ctx context.Context, name string) error
+
+	// StreamTraffic reports the current throughput once per second until
+	// ctx is cancelled, then closes the channel.
+	StreamTraffic(ctx context.Context) <-chan Traffic
+	// StreamLogs reports the log entries of level and above until ctx is
+	// cancelled, then closes the channel.
+	StreamLogs(ctx context.Context, level string) <-chan LogEntry
+}
+
+var (
+	_ Backend = (*Client)(nil)
+	_ Backend = (*Mock)(nil)
+)
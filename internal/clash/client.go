@@ -2,104 +2,193 @@ package clash
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
-	"os"
-	"sync"
+	"net/url"
+	"time"
 )
 
 const (
-	defaultClashURL = "http://127.0.0.1:9090"
-	proxiesPath     = "/proxies"
+	defaultClashURL       = "http://127.0.0.1:9090"
+	proxiesPath           = "/proxies"
+	defaultConnectTimeout = 3 * time.Second
+	defaultReadTimeout    = 10 * time.Second
 )
 
-var (
-	mockMode  = os.Getenv("MOCK_CLASH") == "1"
-	apiSecret = os.Getenv("MIHOMO_SECRET")
-)
+// Timeouts bounds how long the client waits for the controller. Connect
+// covers establishing the TCP connection, Read covers waiting for the
+// response headers once the request is sent. Requests the controller only
+// answers once it has done the work, like latency tests, wait for that work
+// on top of Read. Zero values pick the defaults.
+type Timeouts struct {
+	Connect time.Duration
+	Read    time.Duration
+}
 
+// Client is the Backend that talks to a Clash/Mihomo RESTful API.
 type Client struct {
-	baseURL       string
-	secret        string
-	httpClient    *http.Client
-	mockProxies   map[string]Proxy
-	mockProxiesMu sync.RWMutex
+	baseURL     string
+	secret      string
+	httpClient  *http.Client
+	slowClient  *http.Client // Without the header timeout, for slowContext
+	readTimeout time.Duration
 }
 
+// Proxy is a node or a group as reported by GET /proxies. Clash sends only
+// part of these fields; the ones Mihomo adds are left zero there.
 type Proxy struct {
-	Name    string                 `json:"name"`
-	Type    string                 `json:"type"`
-	Now     string                 `json:"now"`
-	All     []string               `json:"all"`
-	History []ProxyHistory         `json:"history"`
-	Uptime  string                 `json:"uptime"`
-	Extra   map[string]interface{} `json:"extra"`
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Now     string         `json:"now"`
+	All     []string       `json:"all"`
+	History []ProxyHistory `json:"history"` // Tests against the default URL
+	Uptime  string         `json:"uptime"`
+
+	UDP          bool        `json:"udp"`
+	XUDP         bool        `json:"xudp"`
+	TFO          bool        `json:"tfo"`
+	Alive        *bool       `json:"alive"` // Mihomo only, nil when not sent
+	ProviderName string      `json:"provider-name"`
+	DialerProxy  string      `json:"dialer-proxy"`
+	Extra        ProxyExtras `json:"extra"` // Tests against other URLs
+
+	// Group settings
+	Hidden  bool   `json:"hidden"`
+	Icon    string `json:"icon"`
+	TestURL string `json:"testUrl"`
+	Fixed   string `json:"fixed"` // Member pinned by hand in URLTest and Fallback groups
+}
+
+// ProxyExtra is the state Mihomo keeps for each URL a proxy was tested
+// against, besides the default one.
+type ProxyExtra struct {
+	Alive   bool           `json:"alive"`
+	History []ProxyHistory `json:"history"`
+}
+
+// ProxyExtras maps test URLs to their state.
+type ProxyExtras map[string]ProxyExtra
+
+// UnmarshalJSON drops entries in a shape it does not know instead of
+// failing the whole response, as forks and older versions differ here.
+func (e *ProxyExtras) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		*e = nil
+		return nil
+	}
+	extras := make(ProxyExtras, len(raw))
+	for url, v := range raw {
+		var extra ProxyExtra
+		if json.Unmarshal(v, &extra) == nil {
+			extras[url] = extra
+		}
+	}
+	*e = extras
+	return nil
 }
 
 type ProxyHistory struct {
 	Time  string `json:"time"`
-	Delay int    `json:"delay"`
+	Delay int    `json:"delay"` // 0 when the test timed out
+}
+
+// At parses the time of the measurement. The zero time is returned when the
+// controller sent something unexpected.
+func (h ProxyHistory) At() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, h.Time)
+	return t
+}
+
+// LastHistory returns the most recent latency measurement of the proxy.
+func (p Proxy) LastHistory() (ProxyHistory, bool) {
+	return lastHistory(p.History)
+}
+
+// HistoryFor returns the measurements taken against url, falling back to
+// the default history when the controller keeps none for it.
+func (p Proxy) HistoryFor(url string) []ProxyHistory {
+	if extra, ok := p.Extra[url]; ok && len(extra.History) > 0 {
+		return extra.History
+	}
+	return p.History
+}
+
+// LastHistoryFor returns the most recent measurement against url.
+func (p Proxy) LastHistoryFor(url string) (ProxyHistory, bool) {
+	return lastHistory(p.HistoryFor(url))
+}
+
+func lastHistory(history []ProxyHistory) (ProxyHistory, bool) {
+	if len(history) == 0 {
+		return ProxyHistory{}, false
+	}
+	return history[len(history)-1], true
+}
+
+// IsAlive reports whether the proxy answered its latest test, and whether
+// that is known at all. Clash does not send alive, so there it is read from
+// the history.
+func (p Proxy) IsAlive() (alive, known bool) {
+	if p.Alive != nil {
+		return *p.Alive, true
+	}
+	if h, ok := p.LastHistory(); ok {
+		return h.Delay > 0, true
+	}
+	return false, false
 }
 
 type ProxiesResponse struct {
 	Proxies map[string]Proxy `json:"proxies"`
 }
 
-func NewClient(baseURL string) *Client {
+func NewClient(baseURL, secret string, timeouts Timeouts) *Client {
 	if baseURL == "" {
 		baseURL = defaultClashURL
 	}
+	if timeouts.Connect <= 0 {
+		timeouts.Connect = defaultConnectTimeout
+	}
+	if timeouts.Read <= 0 {
+		timeouts.Read = defaultReadTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	slow := transport.Clone()
+	transport.ResponseHeaderTimeout = timeouts.Read
+
 	return &Client{
-		baseURL:    baseURL,
-		secret:     apiSecret,
-		httpClient: &http.Client{},
+		baseURL:     baseURL,
+		secret:      secret,
+		httpClient:  &http.Client{Transport: transport},
+		slowClient:  &http.Client{Transport: slow},
+		readTimeout: timeouts.Read,
 	}
 }
 
+// slowContext bounds a request sent with slowClient: the controller answers
+// it only after work taking up to work, so the read timeout applies on top.
+func (c *Client) slowContext(ctx context.Context, work time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, work+c.readTimeout)
+}
+
 func (c *Client) addAuthHeader(req *http.Request) {
 	if c.secret != "" {
 		req.Header.Set("Authorization", "Bearer "+c.secret)
 	}
 }
 
-func (c *Client) GetProxies() (*ProxiesResponse, error) {
-	if mockMode {
-		c.mockProxiesMu.RLock()
-		if c.mockProxies != nil {
-			defer c.mockProxiesMu.RUnlock()
-			return &ProxiesResponse{Proxies: c.mockProxies}, nil
-		}
-		c.mockProxiesMu.RUnlock()
-
-		// Initialize mock data for testing
-		c.mockProxiesMu.Lock()
-		defer c.mockProxiesMu.Unlock()
-		c.mockProxies = make(map[string]Proxy)
-		c.mockProxies["Proxy Group A"] = Proxy{
-			Name: "Proxy Group A",
-			Type: "Selector",
-			Now:  "Proxy-1",
-			All:  []string{"Proxy-1", "Proxy-2", "Proxy-3", "Proxy-4", "Proxy-5", "Proxy-6", "Proxy-7"},
-		}
-		c.mockProxies["Proxy Group B"] = Proxy{
-			Name: "Proxy Group B",
-			Type: "URLTest",
-			Now:  "Auto-2",
-			All:  []string{"Auto-1", "Auto-2", "Auto-3", "Auto-4", "Auto-5", "Auto-6"},
-		}
-		c.mockProxies["Proxy Group C"] = Proxy{
-			Name: "Proxy Group C",
-			Type: "Selector",
-			Now:  "Direct-1",
-			All:  []string{"Direct-1", "Direct-2", "Direct-3", "Direct-4", "Direct-5", "Direct-6", "Direct-7", "Direct-8"},
-		}
-		return &ProxiesResponse{Proxies: c.mockProxies}, nil
-	}
-
-	url := c.baseURL + proxiesPath
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) GetProxies(ctx context.Context) (*ProxiesResponse, error) {
+	u := c.baseURL + proxiesPath
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -112,8 +201,7 @@ func (c *Client) GetProxies() (*ProxiesResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return nil, apiError(resp)
 	}
 
 	var result ProxiesResponse
@@ -124,25 +212,8 @@ func (c *Client) GetProxies() (*ProxiesResponse, error) {
 	return &result, nil
 }
 
-func (c *Client) SelectProxy(groupName, proxyName string) error {
-	if mockMode {
-		c.mockProxiesMu.Lock()
-		defer c.mockProxiesMu.Unlock()
-
-		if proxy, ok := c.mockProxies[groupName]; ok {
-			for _, p := range proxy.All {
-				if p == proxyName {
-					proxy.Now = proxyName
-					c.mockProxies[groupName] = proxy
-					return nil
-				}
-			}
-			return fmt.Errorf("proxy %s not found in group %s", proxyName, groupName)
-		}
-		return fmt.Errorf("group %s not found", groupName)
-	}
-
-	url := c.baseURL + proxiesPath + "/" + groupName
+func (c *Client) SelectProxy(ctx context.Context, groupName, proxyName string) error {
+	u := c.baseURL + proxiesPath + "/" + url.PathEscape(groupName)
 
 	payload := map[string]string{
 		"name": proxyName,
@@ -153,7 +224,7 @@ func (c *Client) SelectProxy(groupName, proxyName string) error {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "PUT", u, bytes.NewBuffer(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -167,52 +238,8 @@ func (c *Client) SelectProxy(groupName, proxyName string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return apiError(resp)
 	}
 
 	return nil
 }
-
-func (c *Client) TestDelay(groupName, proxyName string, testURL string) (int, error) {
-	url := c.baseURL + proxiesPath + "/" + groupName + "/delay"
-
-	if testURL == "" {
-		testURL = "http://www.gstatic.com/generate_204"
-	}
-
-	payload := map[string]string{
-		"url":     testURL,
-		"timeout": "5000",
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return 0, fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	req, err := http.NewRequest("GET", url, bytes.NewBuffer(body))
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	c.addAuthHeader(req)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("failed to test delay: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return 0, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-	}
-
-	var result map[string]int
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	return result["delay"], nil
-}
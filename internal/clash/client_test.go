@@ -0,0 +1,266 @@
+package clash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientReadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewClient(srv.URL, "", Timeouts{Read: 50 * time.Millisecond})
+	start := time.Now()
+	if _, err := c.GetProxies(context.Background()); err == nil {
+		t.Fatalf("Expected an error from a hung controller")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the read timeout to apply, request took %v", elapsed)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewClient(srv.URL, "", Timeouts{})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := c.GetProxies(ctx); err == nil {
+		t.Fatalf("Expected an error after cancelling the request")
+	}
+	if ctx.Err() == nil {
+		t.Errorf("Expected the request to return only after cancellation")
+	}
+}
+
+func TestClientProxyMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"proxies":{
+			"HK-01":{"name":"HK-01","type":"Vmess","udp":true,"xudp":true,"tfo":false,"alive":false,
+				"provider-name":"sub","dialer-proxy":"Jump",
+				"history":[{"time":"2024-01-01T00:00:00Z","delay":120}],
+				"extra":{"https://cp.cloudflare.com":{"alive":false,"history":[{"time":"2024-01-01T00:01:00Z","delay":0}]}}},
+			"Auto":{"name":"Auto","type":"URLTest","now":"HK-01","all":["HK-01"],"hidden":true,
+				"icon":"https://example.com/hk.png","testUrl":"https://cp.cloudflare.com","fixed":"HK-01"},
+			"Old":{"name":"Old","type":"Shadowsocks","udp":false,"history":[{"time":"2024-01-01T00:00:00Z","delay":0}],
+				"extra":"unexpected"}
+		}}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, "", Timeouts{}).GetProxies(context.Background())
+	if err != nil {
+		t.Fatalf("GetProxies failed: %v", err)
+	}
+	hk := resp.Proxies["HK-01"]
+	if !hk.UDP || !hk.XUDP || hk.TFO || hk.ProviderName != "sub" || hk.DialerProxy != "Jump" {
+		t.Errorf("Expected typed node fields, got %+v", hk)
+	}
+	if alive, known := hk.IsAlive(); alive || !known {
+		t.Errorf("Expected the controller's alive flag to be used, got %v/%v", alive, known)
+	}
+	if h, _ := hk.LastHistoryFor("https://cp.cloudflare.com"); h.Delay != 0 {
+		t.Errorf("Expected the history of the other URL, got %+v", h)
+	}
+	if h, _ := hk.LastHistoryFor("http://www.gstatic.com/generate_204"); h.Delay != 120 {
+		t.Errorf("Expected the default history for an untested URL, got %+v", h)
+	}
+
+	auto := resp.Proxies["Auto"]
+	if !auto.Hidden || auto.Icon == "" || auto.TestURL != "https://cp.cloudflare.com" || auto.Fixed != "HK-01" {
+		t.Errorf("Expected typed group fields, got %+v", auto)
+	}
+
+	// Clash sends no alive flag, and a malformed extra is dropped
+	old := resp.Proxies["Old"]
+	if alive, known := old.IsAlive(); alive || !known || old.Extra != nil {
+		t.Errorf("Expected Old to be dead from its history with no extra, got %v/%v %+v", alive, known, old.Extra)
+	}
+}
+
+func TestClientProxyDelay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("url") == "" || q.Get("timeout") != "800" || q.Get("expected") != "204" {
+			t.Errorf("Expected url/timeout/expected query parameters, got %q", r.URL.RawQuery)
+		}
+		switch r.URL.EscapedPath() {
+		case "/proxies/HK%2001/delay":
+			w.Write([]byte(`{"delay":123}`))
+		case "/proxies/Dead/delay":
+			w.WriteHeader(http.StatusRequestTimeout)
+			w.Write([]byte(`{"message":"Timeout"}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"An error occurred in the delay test"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "", Timeouts{})
+	opts := DelayOptions{Timeout: 800 * time.Millisecond, Expected: "204"}
+
+	if r := c.TestProxyDelay(context.Background(), "HK 01", opts); r.Status != DelayOK || r.Delay != 123 {
+		t.Errorf("Expected 123ms, got %+v", r)
+	}
+	if r := c.TestProxyDelay(context.Background(), "Dead", opts); r.Status != DelayTimeout {
+		t.Errorf("Expected timeout, got %+v", r)
+	}
+	if r := c.TestProxyDelay(context.Background(), "Broken", opts); r.Status != DelayError || r.Err == nil {
+		t.Errorf("Expected error, got %+v", r)
+	}
+}
+
+func TestClientSlowDelay(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/proxies/Hung/delay":
+			<-release
+		case "/group/Auto/delay":
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte(`{"A":250}`))
+		default:
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte(`{"delay":250}`))
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	// The controller answers after the test, past the read timeout but
+	// within the delay timeout
+	c := NewClient(srv.URL, "", Timeouts{Read: 100 * time.Millisecond})
+	opts := DelayOptions{Timeout: time.Second}
+	if r := c.TestProxyDelay(context.Background(), "Slow", opts); r.Status != DelayOK || r.Delay != 250 {
+		t.Errorf("Expected a slow test to succeed, got %+v", r)
+	}
+	results, err := c.TestGroupDelay(context.Background(), "Auto", []string{"A"}, opts)
+	if err != nil || results["A"].Status != DelayOK {
+		t.Errorf("Expected a slow group test to succeed, got %+v (%v)", results, err)
+	}
+
+	// A controller that never answers still gives up
+	start := time.Now()
+	if r := c.TestProxyDelay(context.Background(), "Hung", DelayOptions{Timeout: 100 * time.Millisecond}); r.Status != DelayError {
+		t.Errorf("Expected an error from a hung controller, got %+v", r)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the delay and read timeouts to apply, request took %v", elapsed)
+	}
+}
+
+func TestClientGroupDelay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/group/Auto/delay" {
+			t.Errorf("Unexpected path %q", r.URL.Path)
+		}
+		w.Write([]byte(`{"A":80,"B":0}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "", Timeouts{})
+	results, err := c.TestGroupDelay(context.Background(), "Auto", []string{"A", "B", "C"}, DelayOptions{})
+	if err != nil {
+		t.Fatalf("TestGroupDelay failed: %v", err)
+	}
+	if results["A"].Status != DelayOK || results["A"].Delay != 80 {
+		t.Errorf("Expected A to answer in 80ms, got %+v", results["A"])
+	}
+	for _, name := range []string{"B", "C"} {
+		if results[name].Status != DelayTimeout {
+			t.Errorf("Expected %s to time out, got %+v", name, results[name])
+		}
+	}
+}
+
+func TestClientProviders(t *testing.T) {
+	var updated []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/providers/rules":
+			w.Write([]byte(`{"providers":{"ads":{"name":"ads","behavior":"Domain","format":"MrsRule",` +
+				`"ruleCount":1532,"updatedAt":"2026-10-01T08:00:00Z","vehicleType":"HTTP"}}}`))
+		case r.Method == "PUT":
+			updated = append(updated, r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"resource not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "", Timeouts{})
+	resp, err := c.GetRuleProviders(context.Background())
+	if err != nil {
+		t.Fatalf("GetRuleProviders failed: %v", err)
+	}
+	ads := resp.Providers["ads"]
+	if ads.RuleCount != 1532 || ads.Behavior != "Domain" || ads.UpdatedAt.IsZero() {
+		t.Errorf("Unexpected rule provider %+v", ads)
+	}
+
+	if err := c.UpdateRuleProvider(context.Background(), "ads"); err != nil {
+		t.Errorf("UpdateRuleProvider failed: %v", err)
+	}
+	if err := c.UpdateProxyProvider(context.Background(), "my sub"); err != nil {
+		t.Errorf("UpdateProxyProvider failed: %v", err)
+	}
+	if len(updated) != 2 || updated[0] != "/providers/rules/ads" || updated[1] != "/providers/proxies/my%20sub" {
+		t.Errorf("Unexpected update requests %q", updated)
+	}
+	if err := c.HealthCheckProxyProvider(context.Background(), "missing"); err == nil {
+		t.Errorf("Expected an error for an unknown provider")
+	}
+}
+
+func TestClientSlowProviderAction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Answered once every node was tested
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "", Timeouts{Read: 100 * time.Millisecond})
+	if err := c.HealthCheckProxyProvider(context.Background(), "sub"); err != nil {
+		t.Errorf("Expected a slow health check to succeed, got %v", err)
+	}
+	if err := c.UpdateRuleProvider(context.Background(), "ads"); err != nil {
+		t.Errorf("Expected a slow update to succeed, got %v", err)
+	}
+}
+
+func TestClientPatchConfig(t *testing.T) {
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/configs" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "", Timeouts{})
+	if err := c.PatchConfig(context.Background(), map[string]interface{}{"mode": "global"}); err != nil {
+		t.Fatalf("PatchConfig failed: %v", err)
+	}
+	if got["mode"] != "global" || len(got) != 1 {
+		t.Errorf("Expected only the mode to be sent, got %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package clash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const configsPath = "/configs"
+
+// Modes are the routing modes of the controller, in the order the TUI
+// cycles through them.
+var Modes = []string{"rule", "global", "direct"}
+
+// RuntimeConfig is the part of the controller's running configuration
+// reported by GET /configs.
+type RuntimeConfig struct {
+	Port        int       `json:"port"`
+	SocksPort   int       `json:"socks-port"`
+	RedirPort   int       `json:"redir-port"`
+	TProxyPort  int       `json:"tproxy-port"`
+	MixedPort   int       `json:"mixed-port"`
+	AllowLan    bool      `json:"allow-lan"`
+	BindAddress string    `json:"bind-address"`
+	Mode        string    `json:"mode"`
+	LogLevel    string    `json:"log-level"`
+	IPv6        bool      `json:"ipv6"`
+	Tun         TunConfig `json:"tun"`
+}
+
+type TunConfig struct {
+	Enable bool   `json:"enable"`
+	Stack  string `json:"stack"`
+	Device string `json:"device"`
+}
+
+// NormalizedMode returns the mode in lower case; Clash reports "Rule" where
+// Mihomo reports "rule".
+func (c RuntimeConfig) NormalizedMode() string {
+	return strings.ToLower(c.Mode)
+}
+
+func (c *Client) GetConfig(ctx context.Context) (*RuntimeConfig, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+configsPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result RuntimeConfig
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// PatchConfig changes the given keys of the running configuration through
+// PATCH /configs, e.g. {"mode": "global"} or {"tun": {"enable": true}}.
+func (c *Client) PatchConfig(ctx context.Context, patch map[string]interface{}) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", c.baseURL+configsPath, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to patch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	return nil
+}
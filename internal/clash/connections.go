@@ -0,0 +1,120 @@
+package clash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const connectionsPath = "/connections"
+
+type ConnectionsResponse struct {
+	DownloadTotal int64        `json:"downloadTotal"`
+	UploadTotal   int64        `json:"uploadTotal"`
+	Connections   []Connection `json:"connections"`
+}
+
+// Connection is an active connection tracked by the controller. Chains lists
+// the proxies it goes through, starting with the outermost node and ending
+// with the group the rule picked.
+type Connection struct {
+	ID          string             `json:"id"`
+	Metadata    ConnectionMetadata `json:"metadata"`
+	Upload      int64              `json:"upload"`
+	Download    int64              `json:"download"`
+	Start       time.Time          `json:"start"`
+	Chains      []string           `json:"chains"`
+	Rule        string             `json:"rule"`
+	RulePayload string             `json:"rulePayload"`
+}
+
+type ConnectionMetadata struct {
+	Network         string `json:"network"`
+	Type            string `json:"type"`
+	SourceIP        string `json:"sourceIP"`
+	SourcePort      string `json:"sourcePort"`
+	DestinationIP   string `json:"destinationIP"`
+	DestinationPort string `json:"destinationPort"`
+	Host            string `json:"host"`
+	Process         string `json:"process"`
+	ProcessPath     string `json:"processPath"`
+}
+
+// Target returns the host the connection goes to, falling back to the
+// destination IP when the controller did not see a host name.
+func (c Connection) Target() string {
+	if c.Metadata.Host != "" {
+		return c.Metadata.Host
+	}
+	return c.Metadata.DestinationIP
+}
+
+// Through reports whether the connection passes through group, and which of
+// the group's members it uses.
+func (c Connection) Through(group string) (member string, ok bool) {
+	for i, name := range c.Chains {
+		if name == group {
+			if i > 0 {
+				member = c.Chains[i-1]
+			}
+			return member, true
+		}
+	}
+	return "", false
+}
+
+func (c *Client) GetConnections(ctx context.Context) (*ConnectionsResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+connectionsPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connections: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result ConnectionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// CloseConnection closes a single connection through DELETE /connections/{id}.
+func (c *Client) CloseConnection(ctx context.Context, id string) error {
+	return c.deleteConnections(ctx, c.baseURL+connectionsPath+"/"+url.PathEscape(id))
+}
+
+// CloseAllConnections closes every connection through DELETE /connections.
+func (c *Client) CloseAllConnections(ctx context.Context) error {
+	return c.deleteConnections(ctx, c.baseURL+connectionsPath)
+}
+
+func (c *Client) deleteConnections(ctx context.Context, u string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to close connections: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	return nil
+}
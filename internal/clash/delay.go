@@ -0,0 +1,173 @@
+package clash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	groupPath       = "/group"
+	defaultDelayURL = "http://www.gstatic.com/generate_204"
+	defaultTimeout  = 5 * time.Second
+)
+
+// DelayStatus tells the outcome of a latency test apart.
+type DelayStatus int
+
+const (
+	DelayOK      DelayStatus = iota // Delay holds the measured latency
+	DelayTimeout                    // The proxy did not answer within the timeout
+	DelayError                      // The test failed, see Err
+)
+
+// DelayResult is the outcome of testing a single proxy.
+type DelayResult struct {
+	Proxy  string
+	Status DelayStatus
+	Delay  int // Milliseconds, only meaningful when Status is DelayOK
+	Err    error
+	Time   time.Time // When the test finished
+}
+
+// DelayOptions configures a latency test. Expected is Mihomo's optional
+// expected status code range, e.g. "204" or "200-299".
+type DelayOptions struct {
+	URL      string
+	Timeout  time.Duration
+	Expected string
+}
+
+func (o DelayOptions) query() url.Values {
+	if o.URL == "" {
+		o.URL = defaultDelayURL
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	q := url.Values{}
+	q.Set("url", o.URL)
+	q.Set("timeout", strconv.FormatInt(o.Timeout.Milliseconds(), 10))
+	if o.Expected != "" {
+		q.Set("expected", o.Expected)
+	}
+	return q
+}
+
+// TestProxyDelay measures the latency of a single proxy through
+// GET /proxies/{name}/delay. Failures are reported in the result.
+func (c *Client) TestProxyDelay(ctx context.Context, proxyName string, opts DelayOptions) DelayResult {
+	result := c.testProxyDelay(ctx, proxyName, opts)
+	result.Time = time.Now()
+	return result
+}
+
+func (c *Client) testProxyDelay(ctx context.Context, proxyName string, opts DelayOptions) DelayResult {
+	result := DelayResult{Proxy: proxyName}
+
+	ctx, cancel := c.slowContext(ctx, opts.Timeout)
+	defer cancel()
+	u := c.baseURL + proxiesPath + "/" + url.PathEscape(proxyName) + "/delay?" + opts.query().Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		result.Status, result.Err = DelayError, fmt.Errorf("failed to create request: %w", err)
+		return result
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.slowClient.Do(req)
+	if err != nil {
+		result.Status, result.Err = DelayError, fmt.Errorf("failed to test delay: %w", err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		result.Status = DelayTimeout
+		return result
+	default:
+		result.Status, result.Err = DelayError, apiError(resp)
+		return result
+	}
+
+	var body struct {
+		Delay int `json:"delay"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		result.Status, result.Err = DelayError, fmt.Errorf("failed to decode response: %w", err)
+		return result
+	}
+	if body.Delay <= 0 {
+		result.Status = DelayTimeout
+		return result
+	}
+	result.Delay = body.Delay
+	return result
+}
+
+// TestGroupDelay tests every member of a group at once through Mihomo's
+// GET /group/{name}/delay. Members that failed their test are missing from
+// the controller's answer; they are reported as timed out when the group's
+// member list is known from members.
+func (c *Client) TestGroupDelay(ctx context.Context, groupName string, members []string, opts DelayOptions) (map[string]DelayResult, error) {
+	ctx, cancel := c.slowContext(ctx, opts.Timeout)
+	defer cancel()
+	u := c.baseURL + groupPath + "/" + url.PathEscape(groupName) + "/delay?" + opts.query().Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.slowClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to test group delay: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var delays map[string]int
+	if err := json.NewDecoder(resp.Body).Decode(&delays); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return groupResults(delays, members), nil
+}
+
+func groupResults(delays map[string]int, members []string) map[string]DelayResult {
+	now := time.Now()
+	results := make(map[string]DelayResult, len(delays)+len(members))
+	for _, name := range members {
+		results[name] = DelayResult{Proxy: name, Status: DelayTimeout, Time: now}
+	}
+	for name, delay := range delays {
+		if delay > 0 {
+			results[name] = DelayResult{Proxy: name, Status: DelayOK, Delay: delay, Time: now}
+		} else {
+			results[name] = DelayResult{Proxy: name, Status: DelayTimeout, Time: now}
+		}
+	}
+	return results
+}
+
+// apiError turns an unsuccessful response into an error, using the
+// controller's {"message": ...} body when there is one.
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &msg) == nil && msg.Message != "" {
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, msg.Message)
+	}
+	return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+}
@@ -0,0 +1,24 @@
+package clash
+
+import (
+	"context"
+	"net/url"
+)
+
+// LogLevels are the levels the controller can stream, most verbose first.
+var LogLevels = []string{"debug", "info", "warning", "error"}
+
+// LogEntry is one line of the /logs stream.
+type LogEntry struct {
+	Type    string `json:"type"` // Level of the entry: debug, info, warning or error
+	Payload string `json:"payload"`
+}
+
+// StreamLogs streams the controller's log entries of level and above. The
+// stream reconnects by itself and the channel is closed once ctx is
+// cancelled.
+func (c *Client) StreamLogs(ctx context.Context, level string) <-chan LogEntry {
+	out := make(chan LogEntry)
+	go streamJSON(ctx, c, "/logs?level="+url.QueryEscape(level), out)
+	return out
+}
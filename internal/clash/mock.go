@@ -0,0 +1,312 @@
+package clash
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Mock is an in-memory Backend for testing without a running controller.
+type Mock struct {
+	proxies        map[string]Proxy
+	connections    []Connection
+	rules          []Rule
+	proxyProviders map[string]mockProvider
+	ruleProviders  map[string]RuleProvider
+	config         RuntimeConfig
+	mu             sync.RWMutex
+}
+
+// NewMock returns a Mock holding the given proxies. When proxies is nil, a
+// small set of sample groups and their members is used.
+func NewMock(proxies map[string]Proxy) *Mock {
+	if proxies == nil {
+		proxies := sampleProxies()
+		return &Mock{
+			proxies:        proxies,
+			connections:    sampleConnections(proxies),
+			rules:          sampleRules(),
+			proxyProviders: sampleProxyProviders(proxies),
+			ruleProviders:  sampleRuleProviders(),
+			config:         sampleConfig(),
+		}
+	}
+	own := make(map[string]Proxy, len(proxies))
+	for name, p := range proxies {
+		own[name] = p
+	}
+	return &Mock{proxies: own, config: sampleConfig()}
+}
+
+func sampleProxies() map[string]Proxy {
+	proxies := make(map[string]Proxy)
+	proxies["Proxy Group A"] = Proxy{
+		Name: "Proxy Group A",
+		Type: "Selector",
+		Now:  "Proxy-1",
+		All:  []string{"Proxy-1", "Proxy-2", "Proxy-3", "Proxy-4", "Proxy-5", "Proxy-6", "Proxy-7"},
+	}
+	proxies["Proxy Group B"] = Proxy{
+		Name: "Proxy Group B",
+		Type: "URLTest",
+		Now:  "Auto-2",
+		All:  []string{"Auto-1", "Auto-2", "Auto-3", "Auto-4", "Auto-5", "Auto-6"},
+	}
+	proxies["Proxy Group C"] = Proxy{
+		Name: "Proxy Group C",
+		Type: "Selector",
+		Now:  "Direct-1",
+		All:  []string{"Direct-1", "Direct-2", "Direct-3", "Direct-4", "Direct-5", "Direct-6", "Direct-7", "Direct-8"},
+	}
+
+	// Members with a latency measurement taken a few minutes ago
+	measured := time.Now().Add(-3 * time.Minute).Format(time.RFC3339Nano)
+	providers := map[string]string{"Proxy Group A": "sample-subscription", "Proxy Group B": "local-nodes"}
+	for _, group := range []string{"Proxy Group A", "Proxy Group B", "Proxy Group C"} {
+		for i, name := range proxies[group].All {
+			result := mockDelay(name)
+			alive := result.Delay > 0
+			proxies[name] = Proxy{
+				Name:         name,
+				Type:         "Shadowsocks",
+				History:      []ProxyHistory{{Time: measured, Delay: result.Delay}},
+				UDP:          i%3 != 2,
+				Alive:        &alive,
+				ProviderName: providers[group],
+			}
+		}
+	}
+	// Groups picking their proxy themselves, built on the members above
+	proxies["Relay Chain"] = Proxy{
+		Name: "Relay Chain",
+		Type: "Relay",
+		All:  []string{"Proxy-1", "Auto-1"},
+	}
+	proxies["Streaming"] = Proxy{
+		Name: "Streaming",
+		Type: "Fallback",
+		Now:  "Proxy-2",
+		All:  []string{"Proxy-2", "Proxy-3", "Auto-3"},
+	}
+	proxies["Upload Pool"] = Proxy{
+		Name: "Upload Pool",
+		Type: "LoadBalance",
+		All:  []string{"Direct-1", "Direct-2", "Direct-3"},
+	}
+
+	// Built-in proxies and the group used in global mode
+	proxies["DIRECT"] = Proxy{Name: "DIRECT", Type: "Direct", UDP: true}
+	proxies["REJECT"] = Proxy{Name: "REJECT", Type: "Reject"}
+	proxies["GLOBAL"] = Proxy{
+		Name: "GLOBAL",
+		Type: "Selector",
+		Now:  "Proxy Group A",
+		All: []string{"DIRECT", "REJECT", "Proxy Group A", "Proxy Group B", "Proxy Group C",
+			"Relay Chain", "Streaming", "Upload Pool"},
+	}
+	return proxies
+}
+
+func (m *Mock) GetProxies(ctx context.Context) (*ProxiesResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Hand out a copy so callers never share state with the mock
+	proxies := make(map[string]Proxy, len(m.proxies))
+	for name, p := range m.proxies {
+		proxies[name] = p
+	}
+	return &ProxiesResponse{Proxies: proxies}, nil
+}
+
+func (m *Mock) SelectProxy(ctx context.Context, groupName, proxyName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if proxy, ok := m.proxies[groupName]; ok {
+		if proxy.Type == "LoadBalance" || proxy.Type == "Relay" {
+			return fmt.Errorf("group %s does not take a selection", groupName)
+		}
+		for _, p := range proxy.All {
+			if p == proxyName {
+				proxy.Now = proxyName
+				m.proxies[groupName] = proxy
+				return nil
+			}
+		}
+		return fmt.Errorf("proxy %s not found in group %s", proxyName, groupName)
+	}
+	return fmt.Errorf("group %s not found", groupName)
+}
+
+// TestProxyDelay returns a stable fake result derived from the proxy name:
+// most proxies answer within 50-500ms, about one in eight times out.
+func (m *Mock) TestProxyDelay(ctx context.Context, proxyName string, opts DelayOptions) DelayResult {
+	if err := ctx.Err(); err != nil {
+		return DelayResult{Proxy: proxyName, Status: DelayError, Err: err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.knows(proxyName) {
+		return DelayResult{Proxy: proxyName, Status: DelayError, Err: fmt.Errorf("proxy %s not found", proxyName), Time: time.Now()}
+	}
+	result := mockDelay(proxyName)
+	result.Time = time.Now()
+	m.record(result)
+	return result
+}
+
+func (m *Mock) TestGroupDelay(ctx context.Context, groupName string, members []string, opts DelayOptions) (map[string]DelayResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, ok := m.proxies[groupName]
+	if !ok {
+		return nil, fmt.Errorf("group %s not found", groupName)
+	}
+	now := time.Now()
+	results := make(map[string]DelayResult, len(group.All))
+	for _, name := range group.All {
+		result := mockDelay(name)
+		result.Time = now
+		m.record(result)
+		results[name] = result
+	}
+	return results, nil
+}
+
+// record appends a test result to the proxy's history, like the real
+// controllers do.
+func (m *Mock) record(result DelayResult) {
+	proxy, ok := m.proxies[result.Proxy]
+	if !ok {
+		return
+	}
+	entry := ProxyHistory{Time: result.Time.Format(time.RFC3339Nano), Delay: result.Delay}
+	proxy.History = append(proxy.History[:len(proxy.History):len(proxy.History)], entry)
+	alive := result.Delay > 0
+	proxy.Alive = &alive
+	m.proxies[result.Proxy] = proxy
+}
+
+// knows reports whether name is a group or a member of one.
+func (m *Mock) knows(name string) bool {
+	if _, ok := m.proxies[name]; ok {
+		return true
+	}
+	for _, p := range m.proxies {
+		for _, member := range p.All {
+			if member == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mockDelay(proxyName string) DelayResult {
+	h := fnv.New32a()
+	h.Write([]byte(proxyName))
+	sum := h.Sum32()
+	if sum%8 == 0 {
+		return DelayResult{Proxy: proxyName, Status: DelayTimeout}
+	}
+	return DelayResult{Proxy: proxyName, Status: DelayOK, Delay: 50 + int(sum%450)}
+}
+
+func sampleConnections(proxies map[string]Proxy) []Connection {
+	hosts := []struct {
+		host, port, group, rule, payload string
+	}{
+		{"github.com", "443", "Proxy Group A", "DomainSuffix", "github.com"},
+		{"api.github.com", "443", "Proxy Group A", "DomainSuffix", "github.com"},
+		{"www.youtube.com", "443", "Proxy Group B", "DomainKeyword", "youtube"},
+		{"ssh.example.org", "22", "Proxy Group A", "Match", ""},
+		{"cdn.example.net", "80", "Proxy Group C", "GeoIP", "CN"},
+		{"", "853", "Proxy Group C", "IPCIDR", "1.1.1.0/24"},
+	}
+	now := time.Now()
+	conns := make([]Connection, 0, len(hosts))
+	for i, h := range hosts {
+		conns = append(conns, Connection{
+			ID: fmt.Sprintf("mock-%d", i+1),
+			Metadata: ConnectionMetadata{
+				Network:         "tcp",
+				Type:            "HTTPS",
+				SourceIP:        "127.0.0.1",
+				SourcePort:      fmt.Sprintf("%d", 50000+i),
+				DestinationIP:   fmt.Sprintf("93.184.216.%d", 10+i),
+				DestinationPort: h.port,
+				Host:            h.host,
+			},
+			Upload:      int64(2048 * (i + 1)),
+			Download:    int64(65536 * (len(hosts) - i)),
+			Start:       now.Add(-time.Duration(i*i+1) * time.Minute),
+			Chains:      []string{proxies[h.group].Now, h.group},
+			Rule:        h.rule,
+			RulePayload: h.payload,
+		})
+	}
+	return conns
+}
+
+// SetConnections replaces the connections reported by the mock.
+func (m *Mock) SetConnections(conns []Connection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections = append([]Connection(nil), conns...)
+}
+
+func (m *Mock) GetConnections(ctx context.Context) (*ConnectionsResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	resp := &ConnectionsResponse{Connections: append([]Connection(nil), m.connections...)}
+	for _, c := range m.connections {
+		resp.UploadTotal += c.Upload
+		resp.DownloadTotal += c.Download
+	}
+	return resp, nil
+}
+
+func (m *Mock) CloseConnection(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.connections {
+		if c.ID == id {
+			m.connections = append(m.connections[:i:i], m.connections[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("connection %s not found", id)
+}
+
+func (m *Mock) CloseAllConnections(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections = nil
+	return nil
+}
@@ -0,0 +1,77 @@
+package clash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func sampleConfig() RuntimeConfig {
+	return RuntimeConfig{
+		MixedPort:   7890,
+		SocksPort:   7891,
+		BindAddress: "*",
+		Mode:        "rule",
+		LogLevel:    "info",
+		Tun:         TunConfig{Stack: "gvisor", Device: "utun"},
+	}
+}
+
+func (m *Mock) GetConfig(ctx context.Context) (*RuntimeConfig, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cfg := m.config
+	return &cfg, nil
+}
+
+// PatchConfig applies the keys the TUI changes. Like the controller, it
+// rejects values of the wrong type and unknown modes.
+func (m *Mock) PatchConfig(ctx context.Context, patch map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cfg := m.config
+	for key, value := range patch {
+		var ok bool
+		switch key {
+		case "mode":
+			var mode string
+			mode, ok = value.(string)
+			ok = ok && contains(Modes, strings.ToLower(mode))
+			cfg.Mode = strings.ToLower(mode)
+		case "log-level":
+			cfg.LogLevel, ok = value.(string)
+			ok = ok && (contains(LogLevels, cfg.LogLevel) || cfg.LogLevel == "silent")
+		case "allow-lan":
+			cfg.AllowLan, ok = value.(bool)
+		case "ipv6":
+			cfg.IPv6, ok = value.(bool)
+		case "tun":
+			var tun map[string]interface{}
+			if tun, ok = value.(map[string]interface{}); ok {
+				cfg.Tun.Enable, ok = tun["enable"].(bool)
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unexpected status code 400: invalid value for %s", key)
+		}
+	}
+	m.config = cfg
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package clash
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// StreamLogs generates routing log lines for the sample connections every
+// few hundred milliseconds.
+func (m *Mock) StreamLogs(ctx context.Context, level string) <-chan LogEntry {
+	out := make(chan LogEntry)
+	go func() {
+		defer close(out)
+		for {
+			entry := m.sampleLog()
+			if logLevelIndex(entry.Type) >= logLevelIndex(level) {
+				select {
+				case out <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-time.After(time.Duration(200+rand.Intn(800)) * time.Millisecond):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (m *Mock) sampleLog() LogEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.connections) == 0 || rand.Intn(10) == 0 {
+		switch rand.Intn(3) {
+		case 0:
+			return LogEntry{Type: "debug", Payload: "[DNS] cache hit for github.com"}
+		case 1:
+			return LogEntry{Type: "warning", Payload: "[TCP] dial Proxy-3 error: connect failed: i/o timeout"}
+		default:
+			return LogEntry{Type: "error", Payload: "[Provider] sample-provider pull error: 503 Service Unavailable"}
+		}
+	}
+	c := m.connections[rand.Intn(len(m.connections))]
+	rule := c.Rule
+	if c.RulePayload != "" {
+		rule += "(" + c.RulePayload + ")"
+	}
+	return LogEntry{Type: "info", Payload: fmt.Sprintf("[%s] %s:%s --> %s:%s match %s using %s",
+		"TCP", c.Metadata.SourceIP, c.Metadata.SourcePort, c.Target(), c.Metadata.DestinationPort,
+		rule, logChain(c.Chains))}
+}
+
+// logLevelIndex orders levels by verbosity; unknown levels sort with info.
+func logLevelIndex(level string) int {
+	for i, l := range LogLevels {
+		if l == level {
+			return i
+		}
+	}
+	return 1
+}
+
+// logChain renders chains the way the controller logs them: the rule's
+// group first, then the proxy in brackets.
+func logChain(chains []string) string {
+	if len(chains) == 0 {
+		return "DIRECT"
+	}
+	s := chains[len(chains)-1]
+	for i := len(chains) - 2; i >= 0; i-- {
+		s += "[" + chains[i] + "]"
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+package clash
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// mockProvider is a proxy provider of the mock, listing its members by name
+// so the proxies always carry their latest history.
+type mockProvider struct {
+	vehicle string
+	members []string
+	updated time.Time
+	info    *SubscriptionInfo
+}
+
+func sampleProxyProviders(proxies map[string]Proxy) map[string]mockProvider {
+	now := time.Now()
+	return map[string]mockProvider{
+		"sample-subscription": {
+			vehicle: "HTTP",
+			members: proxies["Proxy Group A"].All,
+			updated: now.Add(-5 * time.Hour),
+			info: &SubscriptionInfo{
+				Upload:   3 << 30,
+				Download: 41 << 30,
+				Total:    200 << 30,
+				Expire:   now.Add(40 * 24 * time.Hour).Unix(),
+			},
+		},
+		"local-nodes": {
+			vehicle: "File",
+			members: proxies["Proxy Group B"].All,
+			updated: now.Add(-26 * time.Hour),
+		},
+	}
+}
+
+func (m *Mock) GetProxyProviders(ctx context.Context) (*ProxyProvidersResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	providers := make(map[string]ProxyProvider, len(m.proxyProviders))
+	for name, p := range m.proxyProviders {
+		provider := ProxyProvider{
+			Name:        name,
+			Type:        "Proxy",
+			VehicleType: p.vehicle,
+			UpdatedAt:   p.updated,
+		}
+		if p.info != nil {
+			info := *p.info
+			provider.SubscriptionInfo = &info
+		}
+		for _, member := range p.members {
+			provider.Proxies = append(provider.Proxies, m.proxies[member])
+		}
+		providers[name] = provider
+	}
+	return &ProxyProvidersResponse{Providers: providers}, nil
+}
+
+func (m *Mock) UpdateProxyProvider(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.proxyProviders[name]
+	if !ok {
+		return fmt.Errorf("proxy provider %q not found", name)
+	}
+	p.updated = time.Now()
+	m.proxyProviders[name] = p
+	return nil
+}
+
+func (m *Mock) HealthCheckProxyProvider(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.proxyProviders[name]
+	if !ok {
+		return fmt.Errorf("proxy provider %q not found", name)
+	}
+	for _, member := range p.members {
+		result := mockDelay(member)
+		result.Time = time.Now()
+		m.record(result)
+	}
+	return nil
+}
+
+func sampleRuleProviders() map[string]RuleProvider {
+	now := time.Now()
+	return map[string]RuleProvider{
+		"ads": {
+			Name: "ads", Type: "Rule", VehicleType: "HTTP",
+			Behavior: "Domain", Format: "MrsRule", RuleCount: 1532,
+			UpdatedAt: now.Add(-9 * 24 * time.Hour),
+		},
+		"cn-ip": {
+			Name: "cn-ip", Type: "Rule", VehicleType: "HTTP",
+			Behavior: "IPCIDR", Format: "TextRule", RuleCount: 8421,
+			UpdatedAt: now.Add(-2 * time.Hour),
+		},
+		"private": {
+			Name: "private", Type: "Rule", VehicleType: "File",
+			Behavior: "Classical", Format: "YamlRule", RuleCount: 12,
+			UpdatedAt: now.Add(-30 * 24 * time.Hour),
+		},
+	}
+}
+
+func (m *Mock) GetRuleProviders(ctx context.Context) (*RuleProvidersResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	providers := make(map[string]RuleProvider, len(m.ruleProviders))
+	for name, p := range m.ruleProviders {
+		providers[name] = p
+	}
+	return &RuleProvidersResponse{Providers: providers}, nil
+}
+
+func (m *Mock) UpdateRuleProvider(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.ruleProviders[name]
+	if !ok {
+		return fmt.Errorf("rule provider %q not found", name)
+	}
+	p.UpdatedAt = time.Now()
+	m.ruleProviders[name] = p
+	return nil
+}
@@ -0,0 +1,29 @@
+package clash
+
+import "context"
+
+func (m *Mock) GetRules(ctx context.Context) (*RulesResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rules := make([]Rule, len(m.rules))
+	copy(rules, m.rules)
+	return &RulesResponse{Rules: rules}, nil
+}
+
+func sampleRules() []Rule {
+	return []Rule{
+		{Type: "DomainSuffix", Payload: "github.com", Proxy: "Proxy Group A", Size: -1},
+		{Type: "DomainSuffix", Payload: "githubusercontent.com", Proxy: "Proxy Group A", Size: -1},
+		{Type: "DomainKeyword", Payload: "youtube", Proxy: "Proxy Group B", Size: -1},
+		{Type: "DomainKeyword", Payload: "googlevideo", Proxy: "Proxy Group B", Size: -1},
+		{Type: "RuleSet", Payload: "ads", Proxy: "REJECT", Size: 1532},
+		{Type: "IPCIDR", Payload: "1.1.1.0/24", Proxy: "Proxy Group C", Size: -1},
+		{Type: "DomainSuffix", Payload: "local", Proxy: "DIRECT", Size: -1},
+		{Type: "GeoIP", Payload: "CN", Proxy: "Proxy Group C", Size: -1},
+		{Type: "Match", Payload: "", Proxy: "Proxy Group A", Size: -1},
+	}
+}
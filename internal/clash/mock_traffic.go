@@ -0,0 +1,36 @@
+package clash
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// StreamTraffic generates synthetic traffic, a slow wave with some noise.
+func (m *Mock) StreamTraffic(ctx context.Context) <-chan Traffic {
+	out := make(chan Traffic)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			wave := (math.Sin(float64(i)/5) + 1) / 2
+			t := Traffic{
+				Up:   int64(20_000 + 60_000*wave + rand.Float64()*10_000),
+				Down: int64(200_000 + 1_500_000*wave + rand.Float64()*200_000),
+			}
+			select {
+			case out <- t:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,165 @@
+package clash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	proxyProvidersPath = "/providers/proxies"
+	ruleProvidersPath  = "/providers/rules"
+
+	// providerActionTime is how long the controller may take to fetch a
+	// provider from a slow remote or to health check every node of a large
+	// one, in batches, before it answers
+	providerActionTime = 2 * time.Minute
+)
+
+type ProxyProvidersResponse struct {
+	Providers map[string]ProxyProvider `json:"providers"`
+}
+
+// ProxyProvider is a set of proxies loaded from a subscription or a file.
+// The controller also lists a provider per group with VehicleType
+// "Compatible", holding the proxies defined inline in its config.
+type ProxyProvider struct {
+	Name             string            `json:"name"`
+	Type             string            `json:"type"`
+	VehicleType      string            `json:"vehicleType"` // HTTP, File, Inline or Compatible
+	Proxies          []Proxy           `json:"proxies"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+	SubscriptionInfo *SubscriptionInfo `json:"subscriptionInfo"`
+}
+
+// SubscriptionInfo is the usage reported by a subscription's
+// Subscription-Userinfo header. Expire is a Unix timestamp, 0 if unknown.
+type SubscriptionInfo struct {
+	Upload   int64 `json:"Upload"`
+	Download int64 `json:"Download"`
+	Total    int64 `json:"Total"`
+	Expire   int64 `json:"Expire"`
+}
+
+// Alive counts the provider's proxies whose latest test succeeded, and how
+// many have been tested at all.
+func (p ProxyProvider) Alive() (alive, tested int) {
+	for _, proxy := range p.Proxies {
+		if h, ok := proxy.LastHistory(); ok {
+			tested++
+			if h.Delay > 0 {
+				alive++
+			}
+		}
+	}
+	return alive, tested
+}
+
+func (c *Client) GetProxyProviders(ctx context.Context) (*ProxyProvidersResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+proxyProvidersPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy providers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result ProxyProvidersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// UpdateProxyProvider makes the controller fetch the provider again through
+// PUT /providers/proxies/{name}.
+func (c *Client) UpdateProxyProvider(ctx context.Context, name string) error {
+	return c.providerAction(ctx, "PUT", c.baseURL+proxyProvidersPath+"/"+url.PathEscape(name), "update provider")
+}
+
+// HealthCheckProxyProvider tests every proxy of the provider through
+// GET /providers/proxies/{name}/healthcheck. The controller answers once all
+// tests are done, so this takes up to the provider's test timeout.
+func (c *Client) HealthCheckProxyProvider(ctx context.Context, name string) error {
+	return c.providerAction(ctx, "GET", c.baseURL+proxyProvidersPath+"/"+url.PathEscape(name)+"/healthcheck", "check provider")
+}
+
+// providerAction sends a request whose answer has no body worth reading. The
+// controller answers once the work is done, which may take far longer than
+// the read timeout.
+func (c *Client) providerAction(ctx context.Context, method, u, what string) error {
+	ctx, cancel := c.slowContext(ctx, providerActionTime)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.slowClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return apiError(resp)
+	}
+	return nil
+}
+
+type RuleProvidersResponse struct {
+	Providers map[string]RuleProvider `json:"providers"`
+}
+
+// RuleProvider is a remote or local rule set referenced by RULE-SET rules.
+type RuleProvider struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	VehicleType string    `json:"vehicleType"` // HTTP, File or Inline
+	Behavior    string    `json:"behavior"`    // Domain, IPCIDR or Classical
+	Format      string    `json:"format"`      // e.g. YamlRule, TextRule, MrsRule
+	RuleCount   int       `json:"ruleCount"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+func (c *Client) GetRuleProviders(ctx context.Context) (*RuleProvidersResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+ruleProvidersPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule providers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result RuleProvidersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// UpdateRuleProvider makes the controller fetch the rule set again through
+// PUT /providers/rules/{name}.
+func (c *Client) UpdateRuleProvider(ctx context.Context, name string) error {
+	return c.providerAction(ctx, "PUT", c.baseURL+ruleProvidersPath+"/"+url.PathEscape(name), "update rule provider")
+}
@@ -0,0 +1,46 @@
+package clash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const rulesPath = "/rules"
+
+type RulesResponse struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule is one routing rule, in the order the controller matches them.
+type Rule struct {
+	Type    string `json:"type"`    // e.g. DomainSuffix, GeoIP, Match
+	Payload string `json:"payload"` // What the rule matches, empty for Match
+	Proxy   string `json:"proxy"`   // Target proxy or group
+	Size    int    `json:"size"`    // Entries of a rule set, -1 for other rules
+}
+
+func (c *Client) GetRules(ctx context.Context) (*RulesResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+rulesPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	var result RulesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
+}
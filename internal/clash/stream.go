@@ -0,0 +1,70 @@
+package clash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	streamMinBackoff = time.Second
+	streamMaxBackoff = 15 * time.Second
+)
+
+// streamJSON reads the chunked JSON stream at path and sends every decoded
+// value to out. When the stream breaks it reconnects with exponential
+// backoff. It returns, closing out, once ctx is cancelled.
+func streamJSON[T any](ctx context.Context, c *Client, path string, out chan<- T) {
+	defer close(out)
+
+	backoff := streamMinBackoff
+	for {
+		received, _ := readStream(ctx, c, path, out)
+		if received {
+			backoff = streamMinBackoff
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, streamMaxBackoff)
+	}
+}
+
+// readStream runs a single connection of streamJSON. It reports whether at
+// least one value was received.
+func readStream[T any](ctx context.Context, c *Client, path string, out chan<- T) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return false, err
+	}
+	c.addAuthHeader(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, apiError(resp)
+	}
+
+	received := false
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var v T
+		if err := dec.Decode(&v); err != nil {
+			return received, err
+		}
+		received = true
+		select {
+		case out <- v:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package clash
+
+import "context"
+
+const trafficPath = "/traffic"
+
+// Traffic is one sample of the /traffic stream: bytes per second sent and
+// received over the last second.
+type Traffic struct {
+	Up   int64 `json:"up"`
+	Down int64 `json:"down"`
+}
+
+// StreamTraffic streams a Traffic sample per second. The stream reconnects
+// by itself and the channel is closed once ctx is cancelled.
+func (c *Client) StreamTraffic(ctx context.Context) <-chan Traffic {
+	out := make(chan Traffic)
+	go streamJSON(ctx, c, trafficPath, out)
+	return out
+}
@@ -0,0 +1,427 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLatencyGood = 200 * time.Millisecond
+	DefaultLatencyFair = 500 * time.Millisecond
+)
+
+const (
+	appName          = "proxy-controller-tui"
+	configFileName   = "config.toml"
+	defaultURL       = "http://127.0.0.1:9090"
+	defaultDelayURL  = "http://www.gstatic.com/generate_204"
+	defaultDelayWait = 5 * time.Second
+	defaultDelayJobs = 8
+	defaultConnWait  = 3 * time.Second
+	defaultReadWait  = 10 * time.Second
+)
+
+// Config holds every setting of the application. Values are resolved with
+// the precedence: built-in defaults < config file < environment < flags.
+type Config struct {
+	Controller  Controller
+	Delay       Delay
+	UI          UI
+	Connections Connections
+	Logs        Logs
+	Profiles    []Profile // Controllers to switch between, from [[profiles]]
+	Mock        bool      // Use the built-in mock data instead of a controller
+	Path        string    // Config file that was loaded, empty if none
+}
+
+type Controller struct {
+	URL            string
+	Secret         string
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration // Time to wait for the response headers
+	Profile        string        // Profile used at startup, the first one if empty
+}
+
+// Profile is a named controller that can be switched to at runtime. The
+// timeouts of [controller] apply to every profile.
+type Profile struct {
+	Name   string
+	URL    string
+	Secret string
+}
+
+type Delay struct {
+	URL         string
+	Timeout     time.Duration
+	Expected    string // Mihomo expected status codes, e.g. "204" or "200-299"
+	Concurrency int    // Number of proxies tested at once when testing a group
+}
+
+type UI struct {
+	AltScreen     bool
+	HideGroupType bool // Omit "(Selector)" etc. after group names
+
+	// Latencies up to LatencyGood are shown green, up to LatencyFair yellow
+	// and red above that
+	LatencyGood time.Duration
+	LatencyFair time.Duration
+
+	// Sort is the initial proxy order of every group: "original", "name",
+	// "latency" or "alive"
+	Sort string
+
+	// GroupOrder is the order of the group list: "config" for the order
+	// the groups are defined in, or "name"
+	GroupOrder string
+
+	Traffic        bool // Show the traffic meter above every screen
+	SparklineWidth int  // Seconds of traffic history in the sparklines
+}
+
+type Connections struct {
+	// CloseOnSwitch lists the groups whose connections are closed after
+	// switching their proxy, so traffic moves to the new node right away.
+	// "*" stands for every group.
+	CloseOnSwitch []string
+}
+
+type Logs struct {
+	Level  string // Initial level of the log screen: debug, info, warning or error
+	Buffer int    // Number of log entries kept
+}
+
+// ClosesOnSwitch reports whether switching group closes its connections.
+func (c Connections) ClosesOnSwitch(group string) bool {
+	for _, g := range c.CloseOnSwitch {
+		if g == group || g == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func Default() Config {
+	return Config{
+		Controller: Controller{
+			URL:            defaultURL,
+			ConnectTimeout: defaultConnWait,
+			ReadTimeout:    defaultReadWait,
+		},
+		Delay: Delay{
+			URL:         defaultDelayURL,
+			Timeout:     defaultDelayWait,
+			Concurrency: defaultDelayJobs,
+		},
+		UI: UI{
+			AltScreen:      true,
+			GroupOrder:     "config",
+			LatencyGood:    DefaultLatencyGood,
+			LatencyFair:    DefaultLatencyFair,
+			Traffic:        true,
+			SparklineWidth: 20,
+		},
+		Logs: Logs{
+			Level:  "info",
+			Buffer: 1000,
+		},
+	}
+}
+
+// AdHocProfile names the controller address given with -url or MIHOMO_URL
+// when the config file defines profiles. It is listed first and used at
+// startup unless -profile names another, so no file profile may take it.
+const AdHocProfile = "ad-hoc"
+
+// ProfileList returns the controllers to switch between: the [[profiles]]
+// of the config file, or else the single [controller] as "default".
+func (c Config) ProfileList() []Profile {
+	if len(c.Profiles) > 0 {
+		return c.Profiles
+	}
+	return []Profile{{Name: "default", URL: c.Controller.URL, Secret: c.Controller.Secret}}
+}
+
+// ActiveProfile returns the index in ProfileList of the profile used at
+// startup.
+func (c Config) ActiveProfile() int {
+	for i, p := range c.ProfileList() {
+		if p.Name == c.Controller.Profile {
+			return i
+		}
+	}
+	return 0
+}
+
+// DefaultPath returns the config file location under the XDG config
+// directory ($XDG_CONFIG_HOME, falling back to ~/.config).
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, appName, configFileName)
+}
+
+// Load resolves the configuration from the config file, the environment and
+// the given command-line arguments (without the program name).
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(appName, flag.ContinueOnError)
+	path := fs.String("config", DefaultPath(), "path to the config file")
+	url := fs.String("url", "", "controller address, e.g. http://127.0.0.1:9090")
+	secret := fs.String("secret", "", "controller API secret")
+	profile := fs.String("profile", "", "name of the profile to start with")
+	delayURL := fs.String("delay-url", "", "URL used for latency tests")
+	delayTimeout := fs.Duration("delay-timeout", 0, "timeout of a single latency test")
+	delayJobs := fs.Int("delay-concurrency", 0, "number of proxies tested at once")
+	mock := fs.Bool("mock", false, "use built-in mock data instead of a controller")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *path != "" {
+		f, err := os.Open(*path)
+		switch {
+		case err == nil:
+			err = cfg.readFrom(f)
+			f.Close()
+			if err != nil {
+				return cfg, fmt.Errorf("%s: %w", *path, err)
+			}
+			cfg.Path = *path
+		case errors.Is(err, os.ErrNotExist) && !explicit["config"]:
+			// A missing default config file is fine
+		default:
+			return cfg, err
+		}
+	}
+
+	adHoc := cfg.applyEnv(os.Getenv)
+
+	if explicit["url"] {
+		cfg.Controller.URL = *url
+	}
+	if explicit["secret"] {
+		cfg.Controller.Secret = *secret
+	}
+	// A controller address given outside the config file comes before its
+	// profiles; a secret alone belongs to [controller], unused with profiles
+	adHoc = adHoc || explicit["url"]
+	if adHoc && len(cfg.Profiles) > 0 {
+		cfg.Profiles = append([]Profile{{Name: AdHocProfile, URL: cfg.Controller.URL, Secret: cfg.Controller.Secret}}, cfg.Profiles...)
+		cfg.Controller.Profile = AdHocProfile
+	}
+	if explicit["profile"] {
+		cfg.Controller.Profile = *profile
+	}
+	if explicit["delay-url"] {
+		cfg.Delay.URL = *delayURL
+	}
+	if explicit["delay-timeout"] {
+		cfg.Delay.Timeout = *delayTimeout
+	}
+	if explicit["delay-concurrency"] {
+		cfg.Delay.Concurrency = *delayJobs
+	}
+	if explicit["mock"] {
+		cfg.Mock = *mock
+	}
+
+	cfg.Controller.URL = strings.TrimRight(cfg.Controller.URL, "/")
+	for i := range cfg.Profiles {
+		cfg.Profiles[i].URL = strings.TrimRight(cfg.Profiles[i].URL, "/")
+	}
+	return cfg, cfg.validate()
+}
+
+// applyEnv reads the environment and reports whether it set the controller
+// address.
+func (c *Config) applyEnv(getenv func(string) string) (url bool) {
+	if v := getenv("MIHOMO_URL"); v != "" {
+		c.Controller.URL = v
+		url = true
+	}
+	if v := getenv("MIHOMO_SECRET"); v != "" {
+		c.Controller.Secret = v
+	}
+	if v := getenv("MOCK_CLASH"); v != "" {
+		c.Mock = v == "1"
+	}
+	return url
+}
+
+func (c *Config) readFrom(r io.Reader) error {
+	sections, err := parseTOML(r)
+	if err != nil {
+		return err
+	}
+	for _, s := range sections {
+		if s.array {
+			if err := c.addArrayEntry(s); err != nil {
+				return err
+			}
+			continue
+		}
+		for key, value := range s.keys {
+			if err := c.set(s.name, key, value); err != nil {
+				return fmt.Errorf("line %d: %w", s.line[key], err)
+			}
+		}
+	}
+	return nil
+}
+
+// addArrayEntry applies one [[table]] entry.
+func (c *Config) addArrayEntry(s section) error {
+	if s.name != "profiles" {
+		return fmt.Errorf("unknown array of tables [[%s]]", s.name)
+	}
+	var p Profile
+	for key, value := range s.keys {
+		switch key {
+		case "name":
+			p.Name = value
+		case "url":
+			p.URL = value
+		case "secret":
+			p.Secret = value
+		default:
+			return fmt.Errorf("line %d: unknown key %q in [[profiles]]", s.line[key], key)
+		}
+	}
+	if p.Name == AdHocProfile {
+		return fmt.Errorf("line %d: profile name %q is reserved", s.line["name"], p.Name)
+	}
+	c.Profiles = append(c.Profiles, p)
+	return nil
+}
+
+func (c *Config) set(table, key, value string) error {
+	var err error
+	switch table + "." + key {
+	case ".mock":
+		c.Mock, err = strconv.ParseBool(value)
+	case "controller.url":
+		c.Controller.URL = value
+	case "controller.secret":
+		c.Controller.Secret = value
+	case "controller.connect_timeout":
+		c.Controller.ConnectTimeout, err = parseDuration(value)
+	case "controller.read_timeout":
+		c.Controller.ReadTimeout, err = parseDuration(value)
+	case "controller.profile":
+		c.Controller.Profile = value
+	case "delay.url":
+		c.Delay.URL = value
+	case "delay.timeout":
+		c.Delay.Timeout, err = parseDuration(value)
+	case "delay.expected":
+		c.Delay.Expected = value
+	case "delay.concurrency":
+		c.Delay.Concurrency, err = strconv.Atoi(value)
+	case "ui.alt_screen":
+		c.UI.AltScreen, err = strconv.ParseBool(value)
+	case "ui.hide_group_type":
+		c.UI.HideGroupType, err = strconv.ParseBool(value)
+	case "ui.latency_good":
+		c.UI.LatencyGood, err = parseDuration(value)
+	case "ui.latency_fair":
+		c.UI.LatencyFair, err = parseDuration(value)
+	case "ui.sort":
+		c.UI.Sort = value
+	case "ui.group_order":
+		c.UI.GroupOrder = value
+	case "ui.traffic":
+		c.UI.Traffic, err = strconv.ParseBool(value)
+	case "ui.sparkline_width":
+		c.UI.SparklineWidth, err = strconv.Atoi(value)
+	case "connections.close_on_switch":
+		c.Connections.CloseOnSwitch, err = parseList(value)
+	case "logs.level":
+		c.Logs.Level = value
+	case "logs.buffer":
+		c.Logs.Buffer, err = strconv.Atoi(value)
+	default:
+		if table == "" {
+			return fmt.Errorf("unknown key %q", key)
+		}
+		return fmt.Errorf("unknown key %q in [%s]", key, table)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %q", key, value)
+	}
+	return nil
+}
+
+// parseDuration accepts either a Go duration string ("5s", "800ms") or a
+// plain integer in milliseconds, which is the unit the Clash API uses.
+func parseDuration(v string) (time.Duration, error) {
+	if ms, err := strconv.Atoi(v); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	return time.ParseDuration(v)
+}
+
+func (c *Config) validate() error {
+	if c.Controller.URL == "" {
+		return errors.New("controller URL must not be empty")
+	}
+	if c.Controller.ConnectTimeout <= 0 || c.Controller.ReadTimeout <= 0 {
+		return errors.New("controller timeouts must be positive")
+	}
+	if c.Delay.Timeout <= 0 {
+		return errors.New("delay timeout must be positive")
+	}
+	names := make(map[string]bool, len(c.Profiles))
+	for _, p := range c.Profiles {
+		if p.Name == "" || p.URL == "" {
+			return errors.New("every profile needs a name and a url")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate profile %q", p.Name)
+		}
+		names[p.Name] = true
+	}
+	if c.Controller.Profile != "" && c.ProfileList()[c.ActiveProfile()].Name != c.Controller.Profile {
+		return fmt.Errorf("unknown profile %q", c.Controller.Profile)
+	}
+	switch c.UI.Sort {
+	case "", "original", "name", "latency", "alive":
+	default:
+		return fmt.Errorf("unknown sort mode %q", c.UI.Sort)
+	}
+	switch c.UI.GroupOrder {
+	case "config", "name":
+	default:
+		return fmt.Errorf("unknown group order %q", c.UI.GroupOrder)
+	}
+	if c.UI.LatencyGood > c.UI.LatencyFair {
+		return errors.New("latency_good must not exceed latency_fair")
+	}
+	if c.Delay.Concurrency <= 0 {
+		return errors.New("delay concurrency must be positive")
+	}
+	if c.UI.SparklineWidth <= 0 {
+		return errors.New("sparkline width must be positive")
+	}
+	switch c.Logs.Level {
+	case "debug", "info", "warning", "error":
+	default:
+		return fmt.Errorf("unknown log level %q", c.Logs.Level)
+	}
+	if c.Logs.Buffer <= 0 {
+		return errors.New("log buffer must be positive")
+	}
+	return nil
+}
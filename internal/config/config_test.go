@@ -0,0 +1,220 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFrom(t *testing.T) {
+	cfg := Default()
+	err := cfg.readFrom(strings.NewReader(`
+# Controller settings
+[controller]
+url = "http://10.0.0.1:9097" # trailing comment
+secret = 'p#ss'
+
+[delay]
+url = "http://cp.cloudflare.com"
+timeout = 3000
+
+[ui]
+alt_screen = false
+hide_group_type = true
+group_order = "name"
+
+[connections]
+close_on_switch = ["Proxy", 'Stream, "TV"']
+`))
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+	if cfg.Controller.URL != "http://10.0.0.1:9097" {
+		t.Errorf("Expected controller URL from file, got %q", cfg.Controller.URL)
+	}
+	if cfg.Controller.Secret != "p#ss" {
+		t.Errorf("Expected '#' inside a string to be kept, got %q", cfg.Controller.Secret)
+	}
+	if cfg.Delay.Timeout != 3*time.Second {
+		t.Errorf("Expected integer timeout to be read as milliseconds, got %v", cfg.Delay.Timeout)
+	}
+	if cfg.UI.AltScreen || !cfg.UI.HideGroupType || cfg.UI.GroupOrder != "name" {
+		t.Errorf("Expected UI options from file, got %+v", cfg.UI)
+	}
+	if got := cfg.Connections.CloseOnSwitch; len(got) != 2 || got[1] != `Stream, "TV"` {
+		t.Errorf("Expected a list of groups, got %q", got)
+	}
+	if !cfg.Connections.ClosesOnSwitch("Proxy") || cfg.Connections.ClosesOnSwitch("Other") {
+		t.Errorf("Expected only listed groups to close connections on switch")
+	}
+}
+
+func TestReadFromErrors(t *testing.T) {
+	cases := []string{
+		"[controller]\nport = 9090\n",
+		"[delay]\ntimeout = soon\n",
+		"[ui\n",
+		"url\n",
+		"[controller]\nurl = \"a\"\nurl = \"b\"\n",
+		"[connections]\nclose_on_switch = [\"a\" \"b\"]\n",
+		"[[servers]]\nurl = \"a\"\n",
+		"[[profiles]]\nport = 9090\n",
+		"[[profiles]\n",
+	}
+	for _, c := range cases {
+		cfg := Default()
+		if err := cfg.readFrom(strings.NewReader(c)); err == nil {
+			t.Errorf("Expected error for %q", c)
+		}
+	}
+}
+
+func TestReadFromProfiles(t *testing.T) {
+	cfg := Default()
+	err := cfg.readFrom(strings.NewReader(`
+[controller]
+profile = "router"
+
+[[profiles]]
+name = "workstation"
+url = "http://127.0.0.1:9090"
+
+[[profiles]]
+name = "router"
+url = "http://192.168.1.1:9090/"
+secret = "s3cret"
+`))
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("Expected the profiles to be valid, got %v", err)
+	}
+	list := cfg.ProfileList()
+	if len(list) != 2 || list[1].Name != "router" || list[1].Secret != "s3cret" {
+		t.Fatalf("Expected two profiles in file order, got %+v", list)
+	}
+	if cfg.ActiveProfile() != 1 {
+		t.Errorf("Expected router to be the startup profile, got %d", cfg.ActiveProfile())
+	}
+
+	cfg.Profiles = append(cfg.Profiles, Profile{Name: "router", URL: "http://10.0.0.1:9090"})
+	if err := cfg.validate(); err == nil {
+		t.Errorf("Expected an error for a duplicate profile name")
+	}
+
+	// Without profiles, the controller is the only one
+	if list := Default().ProfileList(); len(list) != 1 || list[0].URL != defaultURL {
+		t.Errorf("Expected a single default profile, got %+v", list)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := "[controller]\nurl = \"http://file:9090\"\nsecret = \"file\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MIHOMO_URL", "")
+	t.Setenv("MIHOMO_SECRET", "env")
+	t.Setenv("MOCK_CLASH", "")
+
+	cfg, err := Load([]string{"-config", path, "-url", "http://flag:9090/"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Controller.URL != "http://flag:9090" {
+		t.Errorf("Expected flag to override file (without trailing slash), got %q", cfg.Controller.URL)
+	}
+	if cfg.Controller.Secret != "env" {
+		t.Errorf("Expected environment to override file, got %q", cfg.Controller.Secret)
+	}
+	if cfg.Path != path {
+		t.Errorf("Expected Path %q, got %q", path, cfg.Path)
+	}
+
+	if _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.toml")}); err == nil {
+		t.Errorf("Expected error for an explicitly given missing config file")
+	}
+}
+
+func TestLoadAdHocProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `
+[controller]
+profile = "router"
+
+[[profiles]]
+name = "workstation"
+url = "http://127.0.0.1:9090"
+
+[[profiles]]
+name = "router"
+url = "http://192.168.1.1:9090"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MIHOMO_URL", "")
+	t.Setenv("MIHOMO_SECRET", "")
+	t.Setenv("MOCK_CLASH", "")
+
+	cfg, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if list := cfg.ProfileList(); len(list) != 2 || list[cfg.ActiveProfile()].Name != "router" {
+		t.Errorf("Expected the file profiles only, starting with router, got %+v", list)
+	}
+
+	// An explicit controller is used at startup, the profiles staying
+	// available to switch to
+	cfg, err = Load([]string{"-config", path, "-url", "http://flag:9090/", "-secret", "flag"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	list := cfg.ProfileList()
+	want := Profile{Name: AdHocProfile, URL: "http://flag:9090", Secret: "flag"}
+	if len(list) != 3 || list[0] != want || cfg.ActiveProfile() != 0 {
+		t.Errorf("Expected %+v first and active, got %+v (active %d)", want, list, cfg.ActiveProfile())
+	}
+
+	t.Setenv("MIHOMO_URL", "http://env:9090")
+	cfg, err = Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if active := cfg.ProfileList()[cfg.ActiveProfile()]; active.Name != AdHocProfile || active.URL != "http://env:9090" {
+		t.Errorf("Expected the environment to override the profile of the file, got %+v", active)
+	}
+
+	// -profile still picks the profile to start with
+	cfg, err = Load([]string{"-config", path, "-profile", "workstation"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if active := cfg.ProfileList()[cfg.ActiveProfile()]; active.Name != "workstation" {
+		t.Errorf("Expected -profile to win over the environment, got %+v", active)
+	}
+
+	// A secret alone does not make a controller of its own
+	t.Setenv("MIHOMO_URL", "")
+	t.Setenv("MIHOMO_SECRET", "env")
+	for _, args := range [][]string{{"-config", path}, {"-config", path, "-secret", "flag"}} {
+		cfg, err = Load(args)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if list := cfg.ProfileList(); len(list) != 2 || list[cfg.ActiveProfile()].Name != "router" {
+			t.Errorf("%v: expected the file profiles only, starting with router, got %+v", args, list)
+		}
+	}
+
+	// The name of the ad-hoc profile is taken
+	cfg = Default()
+	if err := cfg.readFrom(strings.NewReader("[[profiles]]\nname = \"ad-hoc\"\nurl = \"http://a:9090\"\n")); err == nil {
+		t.Errorf("Expected an error for a profile named %q", AdHocProfile)
+	}
+}
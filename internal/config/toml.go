@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// section is one [table] of a config file, or one entry of an [[array]] of
+// tables. Keys outside any table land in a section with an empty name.
+type section struct {
+	name  string
+	array bool
+	keys  map[string]string
+	line  map[string]int
+}
+
+// parseTOML reads the small subset of TOML used by the config file:
+// [tables], [[arrays of tables]], key = value pairs, "strings", 'literal
+// strings', integers, booleans, single-line arrays of strings and # comments.
+// Values are returned unquoted (arrays as written); conversion to the target
+// type happens when the config is applied.
+func parseTOML(r io.Reader) ([]section, error) {
+	sections := []section{{keys: map[string]string{}, line: map[string]int{}}}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			array := strings.HasPrefix(line, "[[")
+			if !strings.HasSuffix(line, "]") || array && !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineNo)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if array {
+				name = strings.TrimSpace(line[2 : len(line)-2])
+			}
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty table name", lineNo)
+			}
+			sections = append(sections, section{name: name, array: array, keys: map[string]string{}, line: map[string]int{}})
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", lineNo)
+		}
+		value, err := unquote(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		cur := &sections[len(sections)-1]
+		if _, dup := cur.keys[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, key)
+		}
+		cur.keys[key] = value
+		cur.line[key] = lineNo
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// stripComment removes a trailing # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(v string) (string, error) {
+	if v == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	switch v[0] {
+	case '[':
+		if _, err := parseList(v); err != nil {
+			return "", err
+		}
+		return v, nil
+	case '"':
+		s, err := strconv.Unquote(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", v)
+		}
+		return s, nil
+	case '\'':
+		if len(v) < 2 || v[len(v)-1] != '\'' {
+			return "", fmt.Errorf("invalid string %s", v)
+		}
+		return v[1 : len(v)-1], nil
+	}
+	return v, nil
+}
+
+// parseList parses a single-line array of strings like ["a", 'b'].
+func parseList(v string) ([]string, error) {
+	if len(v) < 2 || v[0] != '[' || v[len(v)-1] != ']' {
+		return nil, fmt.Errorf("invalid array %s", v)
+	}
+	rest := strings.TrimSpace(v[1 : len(v)-1])
+	var items []string
+	for rest != "" {
+		end, err := stringEnd(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array %s", v)
+		}
+		item, err := unquote(rest[:end])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		rest = strings.TrimSpace(rest[end:])
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("invalid array %s", v)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+	return items, nil
+}
+
+// stringEnd returns the length of the quoted string at the start of s.
+func stringEnd(s string) (int, error) {
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return 0, fmt.Errorf("expected a string")
+	}
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string")
+}
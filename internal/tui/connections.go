@@ -0,0 +1,520 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+const connectionsPollInterval = time.Second
+
+// connSortMode is the order of the connections list.
+type connSortMode int
+
+const (
+	connSortNewest connSortMode = iota
+	connSortDownload
+	connSortUpload
+	connSortHost
+	connSortModeCount
+)
+
+func (s connSortMode) String() string {
+	switch s {
+	case connSortDownload:
+		return "download"
+	case connSortUpload:
+		return "upload"
+	case connSortHost:
+		return "host"
+	default:
+		return "newest"
+	}
+}
+
+// connectionsView is the state of the connections screen.
+type connectionsView struct {
+	list          []clash.Connection
+	uploadTotal   int64
+	downloadTotal int64
+	loaded        bool
+	err           error
+	listCursor
+	selectedID      string // Keeps the cursor on its connection across refreshes
+	sort            connSortMode
+	filter          textInput
+	filtering       bool
+	confirmCloseAll bool
+	pollID          int
+}
+
+type connectionsLoadedMsg struct {
+	resp *clash.ConnectionsResponse
+	err  error
+}
+
+type connectionsTickMsg struct {
+	pollID int
+}
+
+type connectionsClosedMsg struct {
+	count int
+	err   error
+}
+
+func loadConnectionsCmd(ctx context.Context, client clash.Backend) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.GetConnections(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return connectionsLoadedMsg{resp: resp, err: err}
+	}
+}
+
+func connectionsTickCmd(pollID int) tea.Cmd {
+	return tea.Tick(connectionsPollInterval, func(time.Time) tea.Msg {
+		return connectionsTickMsg{pollID: pollID}
+	})
+}
+
+func closeConnectionCmd(ctx context.Context, client clash.Backend, id string) tea.Cmd {
+	return func() tea.Msg {
+		err := client.CloseConnection(ctx, id)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return connectionsClosedMsg{count: 1, err: err}
+	}
+}
+
+func closeAllConnectionsCmd(ctx context.Context, client clash.Backend, count int) tea.Cmd {
+	return func() tea.Msg {
+		err := client.CloseAllConnections(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return connectionsClosedMsg{count: count, err: err}
+	}
+}
+
+// openConnections starts polling the connections while their screen is shown.
+func (m *Model) openConnections() tea.Cmd {
+	m.conns.pollID++
+	return tea.Batch(loadConnectionsCmd(m.context(), m.Client), connectionsTickCmd(m.conns.pollID))
+}
+
+// visibleConnections returns the connections matching the filter, sorted.
+func (m Model) visibleConnections() []clash.Connection {
+	filter := strings.ToLower(m.conns.filter.value)
+	list := make([]clash.Connection, 0, len(m.conns.list))
+	for _, c := range m.conns.list {
+		if filter == "" || strings.Contains(connectionText(c), filter) {
+			list = append(list, c)
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch m.conns.sort {
+		case connSortDownload:
+			return a.Download > b.Download
+		case connSortUpload:
+			return a.Upload > b.Upload
+		case connSortHost:
+			return naturalLess(a.Target(), b.Target())
+		default:
+			return a.Start.After(b.Start)
+		}
+	})
+	return list
+}
+
+// connectionText is what the filter matches against, lower-cased.
+func connectionText(c clash.Connection) string {
+	return strings.ToLower(strings.Join([]string{
+		c.Target(),
+		c.Metadata.DestinationIP + ":" + c.Metadata.DestinationPort,
+		c.Rule, c.RulePayload,
+		strings.Join(c.Chains, " "),
+		c.Metadata.Process,
+	}, " "))
+}
+
+// syncConnectionCursor keeps the cursor on the selected connection after
+// the list changed, and the viewport around the cursor.
+func (m *Model) syncConnectionCursor() {
+	list := m.visibleConnections()
+	for i, c := range list {
+		if c.ID == m.conns.selectedID {
+			m.conns.cursor = i
+			break
+		}
+	}
+	m.conns.sync(len(list), m.listRows())
+	if m.conns.cursor < len(list) {
+		m.conns.selectedID = list[m.conns.cursor].ID
+	}
+}
+
+func (m *Model) moveConnectionCursor(delta int) {
+	list := m.visibleConnections()
+	cursor := m.conns.cursor + delta
+	if cursor < 0 || cursor >= len(list) {
+		return
+	}
+	m.conns.cursor = cursor
+	m.conns.selectedID = list[cursor].ID
+	m.syncConnectionCursor()
+}
+
+func (m Model) updateConnectionsMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case connectionsLoadedMsg:
+		m.conns.loaded = true
+		m.conns.err = msg.err
+		if msg.err == nil {
+			m.conns.list = msg.resp.Connections
+			m.conns.uploadTotal = msg.resp.UploadTotal
+			m.conns.downloadTotal = msg.resp.DownloadTotal
+		}
+		m.syncConnectionCursor()
+		return m, nil
+
+	case connectionsTickMsg:
+		if msg.pollID != m.conns.pollID || m.screen != screenConnections {
+			return m, nil
+		}
+		return m, tea.Batch(loadConnectionsCmd(m.context(), m.Client), connectionsTickCmd(msg.pollID))
+
+	case connectionsClosedMsg:
+		if msg.err != nil {
+			return m, tea.Batch(m.setNotice(fmt.Sprintf("Failed to close connections: %v", msg.err), true),
+				loadConnectionsCmd(m.context(), m.Client))
+		}
+		return m, tea.Batch(m.setNotice(fmt.Sprintf("Closed %d connection%s", msg.count, plural(msg.count)), false),
+			loadConnectionsCmd(m.context(), m.Client))
+	}
+	return m, nil
+}
+
+func (m Model) updateConnectionsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.conns.filtering {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.conns.filtering = false
+			m.conns.filter = textInput{}
+		case tea.KeyEnter:
+			m.conns.filtering = false
+		case tea.KeyCtrlC:
+			return m.quit()
+		default:
+			m.conns.filter.update(msg)
+		}
+		m.syncConnectionCursor()
+		return m, nil
+	}
+
+	if m.conns.confirmCloseAll {
+		m.conns.confirmCloseAll = false
+		if msg.String() == "y" {
+			return m, closeAllConnectionsCmd(m.context(), m.Client, len(m.conns.list))
+		}
+		return m, nil
+	}
+
+	if next, cmd, ok := m.updateListKey(msg, (*Model).moveConnectionCursor); ok {
+		return next, cmd
+	}
+	switch msg.String() {
+	case "/":
+		m.conns.filtering = true
+	case "esc":
+		m.conns.filter = textInput{}
+		m.syncConnectionCursor()
+	case "s":
+		m.conns.sort = (m.conns.sort + 1) % connSortModeCount
+		m.syncConnectionCursor()
+	case "r":
+		return m, loadConnectionsCmd(m.context(), m.Client)
+	case "x":
+		list := m.visibleConnections()
+		if m.conns.cursor < len(list) {
+			return m, closeConnectionCmd(m.context(), m.Client, list[m.conns.cursor].ID)
+		}
+	case "X":
+		if len(m.conns.list) > 0 {
+			m.conns.confirmCloseAll = true
+		}
+	}
+	return m, nil
+}
+
+// Column widths of the connections table. Host, rule and chain are shown
+// at full width when the terminal allows and shrink down to their minimum
+// otherwise.
+const (
+	connHostWidth     = 28
+	connDestWidth     = 21
+	connRuleWidth     = 24
+	connChainWidth    = 28
+	connBytesWidth    = 9
+	connAgeWidth      = 4
+	connHostMinWidth  = 12
+	connRuleMinWidth  = 10
+	connChainMinWidth = 12
+)
+
+// connColumns are the widths of the columns that depend on the terminal
+// width. chain is 0 when the chain column is left out.
+type connColumns struct {
+	host, rule, chain int
+}
+
+// connectionColumns sizes the table to the terminal: host, rule and chain
+// shrink first, widest first, then the chain column goes so that
+// destination and traffic always stay.
+func (m Model) connectionColumns() connColumns {
+	cols := connColumns{host: connHostWidth, rule: connRuleWidth, chain: connChainWidth}
+	if m.Width <= 0 {
+		return cols
+	}
+	// Cursor column, destination and traffic, each with the space after the
+	// column before it
+	fixed := 3 + 1 + connDestWidth + 1 + connBytesWidth + 1 + connBytesWidth + 1 + connAgeWidth
+	width := func() int {
+		w := fixed + cols.host + 1 + cols.rule
+		if cols.chain > 0 {
+			w += 1 + cols.chain
+		}
+		return w
+	}
+	// shrink narrows the column furthest above its minimum by one cell
+	shrink := func() bool {
+		type column struct {
+			width *int
+			min   int
+		}
+		columns := []column{{&cols.host, connHostMinWidth}, {&cols.rule, connRuleMinWidth}}
+		if cols.chain > 0 {
+			columns = append(columns, column{&cols.chain, connChainMinWidth})
+		}
+		var widest *int
+		slack := 0
+		for _, c := range columns {
+			if *c.width-c.min > slack {
+				widest, slack = c.width, *c.width-c.min
+			}
+		}
+		if widest == nil {
+			return false
+		}
+		*widest--
+		return true
+	}
+
+	for width() > m.Width && shrink() {
+	}
+	if width() > m.Width && cols.chain > 0 {
+		cols = connColumns{host: connHostWidth, rule: connRuleWidth}
+		for width() > m.Width && shrink() {
+		}
+	}
+	return cols
+}
+
+func (m Model) connectionsView() string {
+	list := m.visibleConnections()
+
+	title := headerStyle.Render(fmt.Sprintf(" Connections %d", len(list)))
+	if len(list) != len(m.conns.list) {
+		title += helpStyle.Render(fmt.Sprintf("/%d", len(m.conns.list)))
+	}
+	title += helpStyle.Render(fmt.Sprintf("  ↑ %s  ↓ %s  sorted by %s",
+		formatBytes(m.conns.uploadTotal), formatBytes(m.conns.downloadTotal), m.conns.sort))
+
+	cols := m.connectionColumns()
+	header := "   " + fit("Host", cols.host) + " " + fit("Destination", connDestWidth) + " " + fit("Rule", cols.rule) + " "
+	if cols.chain > 0 {
+		header += fit("Chain", cols.chain) + " "
+	}
+	header += fmt.Sprintf("%*s %*s %*s", connBytesWidth, "Up", connBytesWidth, "Down", connAgeWidth, "Age")
+	lines := []string{title, separatorStyle.Render(header)}
+
+	rows := m.listRows()
+	now := time.Now()
+	switch {
+	case m.conns.err != nil:
+		lines = append(lines, errorStyle.Render(fmt.Sprintf("   %v", m.conns.err)))
+	case !m.conns.loaded:
+		lines = append(lines, helpStyle.Render("   Loading connections..."))
+	case len(list) == 0:
+		lines = append(lines, helpStyle.Render("   No connections"))
+	}
+	for i := m.conns.offset; i < len(list) && i < m.conns.offset+rows && m.conns.err == nil; i++ {
+		c := list[i]
+		rule := c.Rule
+		if c.RulePayload != "" {
+			rule += "(" + c.RulePayload + ")"
+		}
+		row := fit(c.Target(), cols.host) + " " +
+			fit(c.Metadata.DestinationIP+":"+c.Metadata.DestinationPort, connDestWidth) + " " +
+			fit(rule, cols.rule) + " "
+		if cols.chain > 0 {
+			row += fit(chainText(c.Chains), cols.chain) + " "
+		}
+		row += fmt.Sprintf("%*s %*s %*s", connBytesWidth, formatBytes(c.Upload), connBytesWidth, formatBytes(c.Download),
+			connAgeWidth, formatAge(now.Sub(c.Start), c.Start.IsZero()))
+		if i == m.conns.cursor {
+			lines = append(lines, cursorStyle.Render(">  ")+row)
+		} else {
+			lines = append(lines, "   "+normalStyle.Render(row))
+		}
+	}
+
+	for len(lines) < m.bodyHeight()-minHelpRows {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n" + m.connectionsHelp()
+}
+
+func (m Model) connectionsHelp() string {
+	switch {
+	case m.conns.filtering:
+		return " " + m.conns.filter.view("/") + helpStyle.Render("  [Ent]Done [Esc]Clear")
+	case m.conns.confirmCloseAll:
+		return errorStyle.Render(fmt.Sprintf(" Close all %d connections? [y/N]", len(m.conns.list)))
+	case m.notice.text != "":
+		return m.noticeLine()
+	case m.conns.filter.value != "":
+		return m.helpLine(
+			[]helpKey{{"/" + m.conns.filter.value, 0}},
+			[]helpKey{{"[Esc]Clear", 0}},
+			[]helpKey{{"[x]Close", 1}, {"[X]Close all", 2}},
+			[]helpKey{{"[Tab]Screens", 0}},
+			[]helpKey{{"[q]Quit", 0}},
+		)
+	}
+	return m.helpLine(
+		[]helpKey{{"[↑k]↑", 0}, {"[↓j]↓", 0}},
+		[]helpKey{{"[/]Filter", 1}, {"[s]Sort", 4}},
+		[]helpKey{{"[x]Close", 2}, {"[X]Close all", 5}},
+		[]helpKey{{"[r]Reload", 3}},
+		[]helpKey{{"[Tab]Screens", 0}},
+		[]helpKey{{"[q]Quit", 0}},
+	)
+}
+
+// chainText renders a chain from the rule's group to the outermost node.
+func chainText(chains []string) string {
+	parts := make([]string, len(chains))
+	for i, c := range chains {
+		parts[len(chains)-1-i] = c
+	}
+	return strings.Join(parts, " > ")
+}
+
+// fit pads or truncates s to exactly width display columns.
+func fit(s string, width int) string {
+	w := lipgloss.Width(s)
+	if w <= width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		rw := lipgloss.Width(string(r))
+		if used+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		used += rw
+	}
+	return b.String() + "…" + strings.Repeat(" ", width-1-used)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+type staleConnectionsClosedMsg struct {
+	group string
+	count int
+	err   error
+}
+
+// closeStaleConnectionsCmd closes the connections going through group that
+// still use another member than proxy, typically after a switch.
+func closeStaleConnectionsCmd(ctx context.Context, client clash.Backend, group, proxy string) tea.Cmd {
+	return func() tea.Msg {
+		msg := staleConnectionsClosedMsg{group: group}
+		resp, err := client.GetConnections(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		for _, c := range resp.Connections {
+			if member, ok := c.Through(group); ok && member != proxy {
+				if err := client.CloseConnection(ctx, c.ID); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					msg.err = err
+					return msg
+				}
+				msg.count++
+			}
+		}
+		return msg
+	}
+}
+
+// closesOnSwitch reports whether switching group closes its connections,
+// as set in the config or toggled at runtime.
+func (m Model) closesOnSwitch(group string) bool {
+	if on, ok := m.closeOnSwitch[group]; ok {
+		return on
+	}
+	return m.Config.Connections.ClosesOnSwitch(group)
+}
+
+func (m *Model) toggleCloseOnSwitch() tea.Cmd {
+	if m.CurrentIdx >= len(m.Groups) {
+		return nil
+	}
+	group := m.Groups[m.CurrentIdx]
+	if m.closeOnSwitch == nil {
+		m.closeOnSwitch = make(map[string]bool)
+	}
+	on := !m.closesOnSwitch(group)
+	m.closeOnSwitch[group] = on
+	if on {
+		return m.setNotice("Switching "+group+" closes its connections", false)
+	}
+	return m.setNotice("Switching "+group+" keeps its connections", false)
+}
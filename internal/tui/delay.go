@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"context"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+type delayResultMsg clash.DelayResult
+
+func (m Model) delayOptions() clash.DelayOptions {
+	return clash.DelayOptions{
+		URL:      m.Config.Delay.URL,
+		Timeout:  m.Config.Delay.Timeout,
+		Expected: m.Config.Delay.Expected,
+	}
+}
+
+func testProxyDelayCmd(ctx context.Context, client clash.Backend, proxyName string, opts clash.DelayOptions) tea.Cmd {
+	return func() tea.Msg {
+		result := client.TestProxyDelay(ctx, proxyName, opts)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return delayResultMsg(result)
+	}
+}
+
+// testCursorDelay starts a latency test of the proxy under the cursor.
+func (m *Model) testCursorDelay() tea.Cmd {
+	_, name, ok := m.cursorProxy()
+	if !ok {
+		return nil
+	}
+	return testProxyDelayCmd(m.context(), m.Client, name, m.delayOptions())
+}
+
+// groupTest is a latency test of every member of a group. Results stream in
+// one message per proxy while the test runs.
+type groupTest struct {
+	id      int
+	group   string
+	done    int
+	total   int
+	results <-chan clash.DelayResult
+	cancel  context.CancelFunc
+}
+
+func (t groupTest) running() bool {
+	return t.cancel != nil
+}
+
+type groupTestResultMsg struct {
+	id     int
+	result clash.DelayResult
+}
+
+type groupTestDoneMsg struct {
+	id int
+}
+
+// runDelayTests tests names on a pool of at most concurrency workers. The
+// returned channel is closed once every test finished or ctx is cancelled.
+func runDelayTests(ctx context.Context, client clash.Backend, names []string, opts clash.DelayOptions, concurrency int) <-chan clash.DelayResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(names) {
+		concurrency = len(names)
+	}
+
+	jobs := make(chan string)
+	results := make(chan clash.DelayResult, len(names))
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				result := client.TestProxyDelay(ctx, name, opts)
+				if ctx.Err() != nil {
+					return
+				}
+				results <- result
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			wg.Wait()
+			close(results)
+		}()
+		defer close(jobs)
+		for _, name := range names {
+			select {
+			case jobs <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results
+}
+
+func waitGroupTestCmd(id int, results <-chan clash.DelayResult) tea.Cmd {
+	return func() tea.Msg {
+		result, ok := <-results
+		if !ok {
+			return groupTestDoneMsg{id: id}
+		}
+		return groupTestResultMsg{id: id, result: result}
+	}
+}
+
+// toggleGroupTest starts testing every member of the current group, or
+// cancels the test when one is already running.
+func (m *Model) toggleGroupTest() tea.Cmd {
+	if m.groupTest.running() {
+		m.groupTest.cancel()
+		m.groupTest = groupTest{id: m.groupTest.id}
+		return nil
+	}
+	if m.CurrentIdx >= len(m.Groups) {
+		return nil
+	}
+	group := m.Groups[m.CurrentIdx]
+	proxy, ok := m.Proxies[group]
+	if !ok || len(proxy.All) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(m.context())
+	results := runDelayTests(ctx, m.Client, proxy.All, m.delayOptions(), m.Config.Delay.Concurrency)
+	m.groupTest = groupTest{
+		id:      m.groupTest.id + 1,
+		group:   group,
+		total:   len(proxy.All),
+		results: results,
+		cancel:  cancel,
+	}
+	return waitGroupTestCmd(m.groupTest.id, results)
+}
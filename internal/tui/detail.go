@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+const (
+	detailWidth        = 46 // Outer width of the side panel
+	detailMinListWidth = 50 // Narrowest the list may get beside the panel
+	detailLabelWidth   = 10
+)
+
+// detailSideBySide reports whether the terminal is wide enough for the list
+// with the detail panel next to it; otherwise the panel is shown as a modal.
+func (m Model) detailSideBySide() bool {
+	return m.Width >= detailMinListWidth+detailWidth
+}
+
+// containingGroups returns the listed groups having name as a member.
+func (m Model) containingGroups(name string) []string {
+	var groups []string
+	for _, g := range m.Groups {
+		for _, member := range m.Proxies[g].All {
+			if member == name {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+	return groups
+}
+
+// detailLines describes the proxy in lines of at most width cells.
+func (m Model) detailLines(name string, width int) []string {
+	p := m.Proxies[name]
+	lines := []string{headerStyle.Render(fit(name, width))}
+	field := func(label, value string) {
+		if value != "" {
+			lines = append(lines, helpStyle.Render(fit(label, detailLabelWidth))+fit(value, width-detailLabelWidth))
+		}
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	field("Type", p.Type)
+	if isGroup(p) {
+		field("Now", p.Now)
+		field("Fixed", p.Fixed)
+		field("Members", fmt.Sprint(len(p.All)))
+		field("Test URL", p.TestURL)
+	} else {
+		field("Provider", p.ProviderName)
+		udp := yesNo(p.UDP)
+		if p.XUDP {
+			udp += " (xudp)"
+		}
+		field("UDP", udp)
+		field("TFO", yesNo(p.TFO))
+		field("Dialer", p.DialerProxy)
+	}
+	if alive, known := p.IsAlive(); known {
+		field("Alive", yesNo(alive))
+	}
+	field("In groups", strings.Join(m.containingGroups(name), ", "))
+
+	lines = append(lines, "", helpStyle.Render("History"))
+	n := len(lines)
+	now := time.Now()
+	if r, ok := m.Delays[name]; ok {
+		lines = append(lines, m.historyLine(r.Time, r.Status, r.Delay, "this session", now))
+	}
+	lines = append(lines, m.historyLines(p.History, "", now)...)
+	urls := make([]string, 0, len(p.Extra))
+	for url := range p.Extra {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		lines = append(lines, m.historyLines(p.Extra[url].History, url, now)...)
+	}
+	if len(lines) == n {
+		lines = append(lines, helpStyle.Render("  not tested yet"))
+	}
+	return lines
+}
+
+// historyLines renders measurements newest first, tagged with the URL they
+// were taken against unless it is the default one.
+func (m Model) historyLines(history []clash.ProxyHistory, url string, now time.Time) []string {
+	lines := make([]string, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		h := history[i]
+		status := clash.DelayOK
+		if h.Delay <= 0 {
+			status = clash.DelayTimeout
+		}
+		lines = append(lines, m.historyLine(h.At(), status, h.Delay, url, now))
+	}
+	return lines
+}
+
+func (m Model) historyLine(at time.Time, status clash.DelayStatus, delay int, source string, now time.Time) string {
+	var value string
+	switch status {
+	case clash.DelayOK:
+		value = m.latencyStyle(delay).Render(fmt.Sprintf("%*s", latencyWidth, fmt.Sprintf("%dms", delay)))
+	case clash.DelayTimeout:
+		value = latencyDeadStyle.Render(fmt.Sprintf("%*s", latencyWidth, "timeout"))
+	default:
+		value = latencyErrorStyle.Render(fmt.Sprintf("%*s", latencyWidth, "error"))
+	}
+	when := "-"
+	if !at.IsZero() {
+		when = at.Local().Format("01-02 15:04:05")
+	}
+	line := "  " + when + " " + value + helpStyle.Render(fmt.Sprintf(" %*s", ageWidth, formatAge(now.Sub(at), at.IsZero())))
+	if source != "" {
+		line += helpStyle.Render(" " + source)
+	}
+	return line
+}
+
+// withDetail adds the detail panel of the proxy under the cursor to the
+// rendered proxies screen: next to the list when there is room, in place of
+// it otherwise.
+func (m Model) withDetail(body string) string {
+	_, name, ok := m.cursorProxy()
+	if !ok || m.finder.open {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	help, list := lines[len(lines)-1], lines[:len(lines)-1]
+
+	// The help line stays below the panel and its border
+	outer := detailWidth
+	if !m.detailSideBySide() {
+		outer = max(m.Width, 20)
+	}
+	rows := max(m.bodyHeight()-minHelpRows-2, 1)
+	content := m.detailLines(name, outer-4)
+	if len(content) > rows {
+		content = content[:rows]
+	}
+	inner := lipgloss.NewStyle().MaxWidth(outer - 4)
+	for i, l := range content {
+		content[i] = inner.Render(l)
+	}
+	box := finderBoxStyle.Width(outer - 2).Height(rows).Render(strings.Join(content, "\n"))
+
+	if !m.detailSideBySide() {
+		return box + "\n" + helpStyle.Render(" [↑k]↑ [↓j]↓  [i/Esc]Close")
+	}
+	listWidth := m.Width - detailWidth
+	cut := lipgloss.NewStyle().MaxWidth(listWidth)
+	for i, l := range list {
+		l = cut.Render(l)
+		list[i] = l + strings.Repeat(" ", max(listWidth-lipgloss.Width(l), 0))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, strings.Join(list, "\n"), box) + "\n" +
+		lipgloss.NewStyle().MaxWidth(m.Width).Render(help)
+}
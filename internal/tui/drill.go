@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+// crumb is a step of a drill-down: the group left and the member it was
+// left through.
+type crumb struct {
+	group  string
+	member string
+}
+
+// drillTrail is the way down to the group reached by the latest drill-down.
+// It only counts while that group is the current one; moving to any other
+// group some other way leaves the trail behind.
+type drillTrail struct {
+	into  string
+	steps []crumb // Outermost first
+}
+
+// groupIndex returns the position of the named group in the list, or -1 if
+// name is not a listed group.
+func (m Model) groupIndex(name string) int {
+	for i, g := range m.Groups {
+		if g == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// trail returns the steps that led to the current group.
+func (m Model) trail() []crumb {
+	if m.CurrentIdx >= len(m.Groups) || m.Groups[m.CurrentIdx] != m.drill.into {
+		return nil
+	}
+	return m.drill.steps
+}
+
+// drillIn opens the group under the cursor, if the member there is one.
+func (m *Model) drillIn() bool {
+	group, member, ok := m.cursorProxy()
+	if !ok || member == group || m.groupIndex(member) < 0 {
+		return false
+	}
+	steps := m.trail()
+	steps = append(steps[:len(steps):len(steps)], crumb{group: group, member: member})
+	m.jumpTo(member, "")
+	m.drill = drillTrail{into: member, steps: steps}
+	return true
+}
+
+// drillOut goes back to the group the current one was opened from, with
+// the cursor on the member it was opened through.
+func (m *Model) drillOut() bool {
+	steps := m.trail()
+	if len(steps) == 0 {
+		return false
+	}
+	last := steps[len(steps)-1]
+	m.jumpTo(last.group, last.member)
+	m.drill = drillTrail{into: last.group, steps: steps[:len(steps)-1]}
+	return true
+}
+
+// breadcrumb renders the groups the current one was opened from.
+func (m Model) breadcrumb() string {
+	steps := m.trail()
+	if len(steps) == 0 {
+		return ""
+	}
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = s.group
+	}
+	return "from " + strings.Join(names, " › ")
+}
+
+// resolveChain follows Now from group through nested groups down to the
+// proxy the traffic ends up on. cyclic is set when the chain comes back to a
+// group already passed.
+func resolveChain(proxies map[string]clash.Proxy, group string) (chain []string, cyclic bool) {
+	seen := map[string]bool{group: true}
+	for name := proxies[group].Now; name != ""; name = proxies[name].Now {
+		if seen[name] {
+			return chain, true
+		}
+		seen[name] = true
+		chain = append(chain, name)
+		if !isGroup(proxies[name]) {
+			break
+		}
+	}
+	return chain, false
+}
+
+// chainText renders the resolved chain of group for its header.
+func (m Model) chainText(group string) string {
+	chain, cyclic := resolveChain(m.Proxies, group)
+	if len(chain) == 0 {
+		return ""
+	}
+	s := helpStyle.Render(" → " + strings.Join(chain, " → "))
+	if cyclic {
+		s += errorStyle.Render(" ↻ cycle")
+	}
+	return s
+}
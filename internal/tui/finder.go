@@ -0,0 +1,192 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// finder is the pop-up fuzzy picker over every (group, proxy) pair.
+type finder struct {
+	open    bool
+	input   textInput
+	cursor  int
+	results []finderMatch
+}
+
+type finderMatch struct {
+	group string
+	proxy string
+	score int
+}
+
+// fuzzyScore matches every whitespace-separated token of query as a
+// case-insensitive subsequence of target. Consecutive characters score
+// higher, so "hk 01" ranks "HK-01" above "Hong Kong 10". It returns false
+// when any token does not match.
+func fuzzyScore(query, target string) (int, bool) {
+	target = strings.ToLower(target)
+	total := 0
+	for _, token := range strings.Fields(strings.ToLower(query)) {
+		score, ok := subsequenceScore(token, target)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	return total, true
+}
+
+func subsequenceScore(token, target string) (int, bool) {
+	// Prefer a contiguous occurrence when there is one
+	if strings.Contains(target, token) {
+		return 3 * len(token), true
+	}
+	score, prev := 0, -2
+	t := []rune(target)
+	i := 0
+	for _, r := range token {
+		for i < len(t) && t[i] != r {
+			i++
+		}
+		if i == len(t) {
+			return 0, false
+		}
+		if i == prev+1 {
+			score += 2
+		} else {
+			score++
+		}
+		prev = i
+		i++
+	}
+	return score, true
+}
+
+// refreshFinder recomputes the results for the current query, best first and
+// otherwise in group order.
+func (m *Model) refreshFinder() {
+	query := m.finder.input.value
+	var results []finderMatch
+	for _, group := range m.Groups {
+		proxy, ok := m.Proxies[group]
+		if !ok {
+			continue
+		}
+		for _, name := range proxy.All {
+			score, ok := fuzzyScore(query, group+" "+name)
+			if ok {
+				results = append(results, finderMatch{group: group, proxy: name, score: score})
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].score > results[j].score
+	})
+	m.finder.results = results
+	m.finder.cursor = 0
+}
+
+func (m *Model) openFinder() {
+	m.finder = finder{open: true}
+	m.refreshFinder()
+}
+
+// jumpTo shows the given group with the cursor on proxy.
+func (m *Model) jumpTo(group, proxy string) {
+	for i, g := range m.Groups {
+		if g == group {
+			m.CurrentIdx = i
+			m.search = textInput{}
+			m.searching = false
+			m.ViewportOffset = 0
+			m.Cursor = 0
+			if !m.placeCursor(proxy) {
+				m.cursorToActive()
+			}
+			return
+		}
+	}
+}
+
+func (m Model) updateFinder(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.finder = finder{}
+		return m, nil
+	case tea.KeyUp, tea.KeyCtrlK, tea.KeyCtrlP:
+		if m.finder.cursor > 0 {
+			m.finder.cursor--
+		}
+		return m, nil
+	case tea.KeyDown, tea.KeyCtrlJ, tea.KeyCtrlN:
+		if m.finder.cursor < len(m.finder.results)-1 {
+			m.finder.cursor++
+		}
+		return m, nil
+	case tea.KeyEnter:
+		if m.finder.cursor >= len(m.finder.results) {
+			return m, nil
+		}
+		match := m.finder.results[m.finder.cursor]
+		m.finder = finder{}
+		m.jumpTo(match.group, match.proxy)
+		// Alt+Enter also makes the proxy the active one right away
+		if msg.Alt {
+			return m.selectCursorProxy()
+		}
+		return m, nil
+	}
+	if m.finder.input.update(msg) {
+		m.refreshFinder()
+	}
+	return m, nil
+}
+
+var finderBoxStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(lipgloss.Color("45")).
+	Padding(0, 1)
+
+// finderView renders the picker in place of the group list.
+func (m Model) finderView() string {
+	// Border (2 rows), input line and the help line below the box
+	rows := m.bodyHeight() - 2 - 1 - minHelpRows
+	if rows < 1 {
+		rows = 1
+	}
+
+	offset := 0
+	if m.finder.cursor >= rows {
+		offset = m.finder.cursor - rows + 1
+	}
+	end := min(offset+rows, len(m.finder.results))
+
+	lines := []string{m.finder.input.view("> ") + helpStyle.Render(fmt.Sprintf("  %d/%d", len(m.finder.results), m.pairCount()))}
+	for i := offset; i < end; i++ {
+		r := m.finder.results[i]
+		if i == m.finder.cursor {
+			lines = append(lines, cursorStyle.Render("> ")+activeProxyStyle.Render(r.proxy)+helpStyle.Render("  "+r.group))
+		} else {
+			lines = append(lines, "  "+r.proxy+helpStyle.Render("  "+r.group))
+		}
+	}
+
+	box := finderBoxStyle.Render(strings.Join(lines, "\n"))
+	padding := m.bodyHeight() - lipgloss.Height(box) - minHelpRows
+	if padding > 0 {
+		box += strings.Repeat("\n", padding)
+	}
+	return box + "\n" + helpStyle.Render(" [↑↓]Move  [Ent]Jump  [Alt+Ent]Jump & select  [Esc]Close")
+}
+
+func (m Model) pairCount() int {
+	n := 0
+	for _, group := range m.Groups {
+		n += len(m.Proxies[group].All)
+	}
+	return n
+}
@@ -0,0 +1,155 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+const defaultSparklineWidth = 20
+
+// trafficStaleAfter is how long the meter shows the latest rates without a
+// new sample. A variable so tests need not wait that long.
+var trafficStaleAfter = 3 * time.Second
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// trafficMeter keeps the most recent samples of the /traffic stream.
+type trafficMeter struct {
+	started bool
+	samples []clash.Traffic // Oldest first
+	last    time.Time       // When the latest sample arrived
+	stream  <-chan clash.Traffic
+}
+
+type trafficMsg struct {
+	sample clash.Traffic
+	stream <-chan clash.Traffic
+}
+
+// trafficStaleMsg redraws the meter once the latest sample got too old,
+// for when the stream went quiet.
+type trafficStaleMsg struct{}
+
+func trafficStaleCmd() tea.Cmd {
+	return tea.Tick(trafficStaleAfter, func(time.Time) tea.Msg {
+		return trafficStaleMsg{}
+	})
+}
+
+func waitTrafficCmd(stream <-chan clash.Traffic) tea.Cmd {
+	return func() tea.Msg {
+		sample, ok := <-stream
+		if !ok {
+			// Stopped on quit
+			return nil
+		}
+		return trafficMsg{sample: sample, stream: stream}
+	}
+}
+
+// startTraffic subscribes to the traffic stream once, when enabled.
+func (m *Model) startTraffic() tea.Cmd {
+	if m.traffic.started || !m.Config.UI.Traffic {
+		return nil
+	}
+	m.traffic.started = true
+	m.traffic.stream = m.Client.StreamTraffic(m.context())
+	return waitTrafficCmd(m.traffic.stream)
+}
+
+func (m *Model) addTrafficSample(msg trafficMsg) tea.Cmd {
+	if msg.stream != m.traffic.stream {
+		return nil
+	}
+	samples := append(m.traffic.samples[:len(m.traffic.samples):len(m.traffic.samples)], msg.sample)
+	if width := m.sparklineWidth(); len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+	m.traffic.samples = samples
+	m.traffic.last = time.Now()
+	return tea.Batch(waitTrafficCmd(msg.stream), trafficStaleCmd())
+}
+
+func (m Model) sparklineWidth() int {
+	if m.Config.UI.SparklineWidth > 0 {
+		return m.Config.UI.SparklineWidth
+	}
+	return defaultSparklineWidth
+}
+
+// headerLine is the line above every screen: the active profile, the
+// routing mode and the traffic meter. It is empty, and takes no room, until
+// there is something to show.
+func (m Model) headerLine() string {
+	var parts []string
+	if label := m.profileLabel(); label != "" {
+		parts = append(parts, label)
+	}
+	if mode := m.mode(); mode != "" {
+		parts = append(parts, helpStyle.Render(" Mode ")+modeLabel(mode))
+	}
+	if m.traffic.started {
+		parts = append(parts, m.trafficMeter())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (m Model) trafficMeter() string {
+	var up, down string
+	if len(m.traffic.samples) == 0 || time.Since(m.traffic.last) > trafficStaleAfter {
+		up, down = "--", "--"
+	} else {
+		latest := m.traffic.samples[len(m.traffic.samples)-1]
+		up, down = formatBytes(latest.Up)+"/s", formatBytes(latest.Down)+"/s"
+	}
+
+	ups := make([]int64, len(m.traffic.samples))
+	downs := make([]int64, len(m.traffic.samples))
+	for i, s := range m.traffic.samples {
+		ups[i], downs[i] = s.Up, s.Down
+	}
+	width := m.sparklineWidth()
+	return helpStyle.Render(" ↓ ") + trafficDownStyle.Render(fmt.Sprintf("%-11s", down)) +
+		trafficDownStyle.Render(sparkline(downs, width)) +
+		helpStyle.Render("  ↑ ") + trafficUpStyle.Render(fmt.Sprintf("%-11s", up)) +
+		trafficUpStyle.Render(sparkline(ups, width))
+}
+
+func (m Model) headerRows() int {
+	if m.headerLine() == "" {
+		return 0
+	}
+	return 1
+}
+
+// bodyHeight is the height left for the current screen below the header.
+func (m Model) bodyHeight() int {
+	return m.Height - m.headerRows()
+}
+
+// sparkline renders values scaled to their maximum, right-aligned in width
+// columns so the newest sample is always at the same place.
+func sparkline(values []int64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	var peak int64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", width-len(values)))
+	for _, v := range values {
+		level := 0
+		if peak > 0 {
+			level = int(v * int64(len(sparkLevels)-1) / peak)
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// textInput is a minimal single-line text input. Only appending and
+// deleting at the end is supported, which is all a search prompt needs.
+type textInput struct {
+	value string
+}
+
+// update applies an editing key to the input and reports whether the key was
+// consumed.
+func (t *textInput) update(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyRunes:
+		t.value += string(msg.Runes)
+	case tea.KeySpace:
+		t.value += " "
+	case tea.KeyBackspace:
+		if r := []rune(t.value); len(r) > 0 {
+			t.value = string(r[:len(r)-1])
+		}
+	case tea.KeyCtrlW:
+		t.value = strings.TrimRight(t.value, " ")
+		t.value = t.value[:strings.LastIndex(t.value, " ")+1]
+	case tea.KeyCtrlU:
+		t.value = ""
+	default:
+		return false
+	}
+	return true
+}
+
+func (t textInput) view(prompt string) string {
+	return prompt + t.value + cursorStyle.Render("█")
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/config"
+)
+
+const (
+	latencyWidth = 7 // Wide enough for "timeout" and "9999ms"
+	ageWidth     = 4
+)
+
+// latency is the most recent measurement known for a proxy, taken from the
+// controller's history or from a test run in this session.
+type latency struct {
+	delay  int // Milliseconds, 0 when the proxy timed out
+	failed bool
+	at     time.Time
+}
+
+func (l latency) dead() bool {
+	return l.delay <= 0 && !l.failed
+}
+
+func (m Model) latencyOf(name string) (latency, bool) {
+	var l latency
+	found := false
+	if proxy, ok := m.Proxies[name]; ok {
+		if h, ok := proxy.LastHistoryFor(m.Config.Delay.URL); ok {
+			l = latency{delay: h.Delay, at: h.At()}
+			found = true
+		}
+	}
+	if r, ok := m.Delays[name]; ok && (!found || !r.Time.Before(l.at)) {
+		l = latency{at: r.Time}
+		switch r.Status {
+		case clash.DelayOK:
+			l.delay = r.Delay
+		case clash.DelayError:
+			l.failed = true
+		}
+		found = true
+	}
+	return l, found
+}
+
+// isDead reports whether the latest test of the proxy, from this session or
+// by the controller, whichever is newer, got no answer. Without any test
+// result the controller's alive flag decides.
+func (m Model) isDead(name string) bool {
+	if l, ok := m.latencyOf(name); ok {
+		return l.dead()
+	}
+	alive, known := m.Proxies[name].IsAlive()
+	return known && !alive
+}
+
+// hasLatency reports whether any of names has a latency to show, so groups
+// that were never tested don't get an empty column.
+func (m Model) hasLatency(names []string) bool {
+	for _, name := range names {
+		if _, ok := m.latencyOf(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// latencyColumn renders the right-aligned latency of a proxy followed by the
+// age of the measurement, or blanks of the same width when nothing is known.
+func (m Model) latencyColumn(name string, now time.Time) string {
+	l, ok := m.latencyOf(name)
+	if !ok {
+		return fmt.Sprintf("%*s", latencyWidth+1+ageWidth, "")
+	}
+
+	var value string
+	switch {
+	case l.failed:
+		value = latencyErrorStyle.Render(fmt.Sprintf("%*s", latencyWidth, "error"))
+	case l.dead():
+		value = latencyDeadStyle.Render(fmt.Sprintf("%*s", latencyWidth, "timeout"))
+	default:
+		value = m.latencyStyle(l.delay).Render(fmt.Sprintf("%*s", latencyWidth, fmt.Sprintf("%dms", l.delay)))
+	}
+	return value + helpStyle.Render(fmt.Sprintf(" %*s", ageWidth, formatAge(now.Sub(l.at), l.at.IsZero())))
+}
+
+func (m Model) latencyStyle(delay int) lipgloss.Style {
+	good, fair := m.Config.UI.LatencyGood, m.Config.UI.LatencyFair
+	if good <= 0 && fair <= 0 {
+		good, fair = config.DefaultLatencyGood, config.DefaultLatencyFair
+	}
+	d := time.Duration(delay) * time.Millisecond
+	switch {
+	case d <= good:
+		return latencyGoodStyle
+	case d <= fair:
+		return latencyFairStyle
+	default:
+		return latencySlowStyle
+	}
+}
+
+// formatAge renders how old a measurement is in a compact form like "45s",
+// "3m" or "2h".
+func formatAge(d time.Duration, unknown bool) string {
+	switch {
+	case unknown:
+		return "?"
+	case d < time.Second:
+		return "now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+}
@@ -0,0 +1,49 @@
+package tui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// listCursor is the cursor of a list screen and the first row shown.
+type listCursor struct {
+	cursor int
+	offset int
+}
+
+// sync keeps the cursor on one of total items and the viewport of rows
+// lines around it.
+func (c *listCursor) sync(total, rows int) {
+	c.cursor = max(min(c.cursor, total-1), 0)
+	if c.cursor < c.offset {
+		c.offset = c.cursor
+	} else if c.cursor >= c.offset+rows {
+		c.offset = c.cursor - rows + 1
+	}
+	c.offset = max(min(c.offset, total-rows), 0)
+}
+
+// listRows is the number of items a list screen shows below its title and
+// column header, above the help line.
+func (m Model) listRows() int {
+	return max(m.bodyHeight()-3, 1)
+}
+
+// updateListKey handles the keys every screen besides the proxies one
+// shares: quitting, cycling the screens and moving up and down, by calling
+// move. ok is false for any other key, left to the screen.
+func (m Model) updateListKey(msg tea.KeyMsg, move func(m *Model, delta int)) (next tea.Model, cmd tea.Cmd, ok bool) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		next, cmd = m.quit()
+		return next, cmd, true
+	case "tab":
+		cmd = m.cycleScreen(1)
+	case "shift+tab":
+		cmd = m.cycleScreen(-1)
+	case "up", "k", "ctrl+k":
+		move(&m, -1)
+	case "down", "j", "ctrl+j":
+		move(&m, 1)
+	default:
+		return m, nil, false
+	}
+	return m, cmd, true
+}
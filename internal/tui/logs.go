@@ -0,0 +1,413 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+const (
+	defaultLogLevel  = "info"
+	defaultLogBuffer = 1000
+)
+
+// logLine is a log entry stamped with the time it arrived; the controller
+// does not send one.
+type logLine struct {
+	time  time.Time
+	level string
+	text  string
+}
+
+// logsView is the state of the logs screen. The stream is opened the first
+// time the screen is shown and keeps filling the buffer in the background.
+type logsView struct {
+	started   bool
+	level     string
+	stream    <-chan clash.LogEntry
+	cancel    context.CancelFunc // Stops the stream, to change the level
+	lines     []logLine          // Oldest first, at most the buffer size
+	held      []logLine          // Received while paused
+	paused    bool
+	scrolled  bool // Whether the view stays at offset instead of following new lines
+	offset    int  // First visible line of the filtered list when scrolled
+	filter    textInput
+	filtering bool
+	saving    bool
+	savePath  textInput
+}
+
+type logEntryMsg struct {
+	line   logLine
+	stream <-chan clash.LogEntry
+}
+
+type logsSavedMsg struct {
+	path  string
+	count int
+	err   error
+}
+
+func waitLogCmd(stream <-chan clash.LogEntry) tea.Cmd {
+	return func() tea.Msg {
+		entry, ok := <-stream
+		if !ok {
+			// Stopped on quit or level change
+			return nil
+		}
+		return logEntryMsg{
+			line:   logLine{time: time.Now(), level: entry.Type, text: entry.Payload},
+			stream: stream,
+		}
+	}
+}
+
+// saveLogsCmd writes lines to path, one entry per line.
+func saveLogsCmd(path string, lines []logLine) tea.Cmd {
+	return func() tea.Msg {
+		var b strings.Builder
+		for _, l := range lines {
+			fmt.Fprintf(&b, "%s %-7s %s\n", l.time.Format("2006-01-02 15:04:05"), strings.ToUpper(l.level), l.text)
+		}
+		err := os.WriteFile(path, []byte(b.String()), 0o644)
+		return logsSavedMsg{path: path, count: len(lines), err: err}
+	}
+}
+
+// openLogs subscribes to the log stream the first time the screen is shown.
+func (m *Model) openLogs() tea.Cmd {
+	if m.logs.started {
+		return nil
+	}
+	m.logs.started = true
+	m.logs.level = m.Config.Logs.Level
+	if m.logs.level == "" {
+		m.logs.level = defaultLogLevel
+	}
+	return m.subscribeLogs()
+}
+
+// subscribeLogs (re)opens the log stream at the current level.
+func (m *Model) subscribeLogs() tea.Cmd {
+	if m.logs.cancel != nil {
+		m.logs.cancel()
+	}
+	ctx, cancel := context.WithCancel(m.context())
+	m.logs.cancel = cancel
+	m.logs.stream = m.Client.StreamLogs(ctx, m.logs.level)
+	return waitLogCmd(m.logs.stream)
+}
+
+// cycleLogLevel switches the stream to the next level. Lines already
+// received are kept.
+func (m *Model) cycleLogLevel() tea.Cmd {
+	next := 0
+	for i, l := range clash.LogLevels {
+		if l == m.logs.level {
+			next = (i + 1) % len(clash.LogLevels)
+		}
+	}
+	m.logs.level = clash.LogLevels[next]
+	return tea.Batch(m.subscribeLogs(), m.setNotice("Log level: "+m.logs.level, false))
+}
+
+func (m Model) logBuffer() int {
+	if m.Config.Logs.Buffer > 0 {
+		return m.Config.Logs.Buffer
+	}
+	return defaultLogBuffer
+}
+
+// appendLogs adds lines to the buffer, dropping the oldest ones beyond its
+// size. A scrolled view keeps showing the same lines.
+func (m *Model) appendLogs(add ...logLine) {
+	lines := append(m.logs.lines[:len(m.logs.lines):len(m.logs.lines)], add...)
+	if over := len(lines) - m.logBuffer(); over > 0 {
+		if m.logs.scrolled {
+			for _, l := range lines[:over] {
+				if m.logMatches(l) {
+					m.logs.offset--
+				}
+			}
+		}
+		lines = lines[over:]
+	}
+	m.logs.lines = lines
+	m.clampLogScroll()
+}
+
+func (m *Model) holdLog(line logLine) {
+	held := append(m.logs.held[:len(m.logs.held):len(m.logs.held)], line)
+	if over := len(held) - m.logBuffer(); over > 0 {
+		held = held[over:]
+	}
+	m.logs.held = held
+}
+
+func (m *Model) togglePauseLogs() {
+	m.logs.paused = !m.logs.paused
+	if !m.logs.paused {
+		m.appendLogs(m.logs.held...)
+		m.logs.held = nil
+	}
+}
+
+func (m Model) logMatches(l logLine) bool {
+	filter := m.logs.filter.value
+	return filter == "" || matchesFilter(l.level+" "+l.text, filter)
+}
+
+// visibleLogs returns the buffered lines matching the filter.
+func (m Model) visibleLogs() []logLine {
+	if m.logs.filter.value == "" {
+		return m.logs.lines
+	}
+	list := make([]logLine, 0, len(m.logs.lines))
+	for _, l := range m.logs.lines {
+		if m.logMatches(l) {
+			list = append(list, l)
+		}
+	}
+	return list
+}
+
+func (m Model) logRows() int {
+	// Title and help line
+	return max(m.bodyHeight()-2, 1)
+}
+
+// scrollLogs moves the view by delta lines. Scrolling back to the end
+// follows new lines again.
+func (m *Model) scrollLogs(delta int) {
+	if !m.logs.scrolled {
+		m.logs.offset = len(m.visibleLogs()) - m.logRows()
+	}
+	m.logs.offset += delta
+	m.logs.scrolled = true
+	m.clampLogScroll()
+}
+
+func (m *Model) clampLogScroll() {
+	if !m.logs.scrolled {
+		return
+	}
+	last := len(m.visibleLogs()) - m.logRows()
+	if m.logs.offset >= last {
+		m.logs.scrolled = false
+	}
+	m.logs.offset = max(min(m.logs.offset, last), 0)
+}
+
+// defaultLogPath is where the buffer is saved unless another path is typed.
+func defaultLogPath() string {
+	return "proxy-logs-" + time.Now().Format("20060102-150405") + ".log"
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+func (m Model) updateLogsMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case logEntryMsg:
+		if msg.stream != m.logs.stream {
+			return m, nil
+		}
+		if m.logs.paused {
+			m.holdLog(msg.line)
+		} else {
+			m.appendLogs(msg.line)
+		}
+		return m, waitLogCmd(msg.stream)
+
+	case logsSavedMsg:
+		if msg.err != nil {
+			return m, m.setNotice(fmt.Sprintf("Failed to save logs: %v", msg.err), true)
+		}
+		return m, m.setNotice(fmt.Sprintf("Saved %d log line%s to %s", msg.count, plural(msg.count), msg.path), false)
+	}
+	return m, nil
+}
+
+func (m Model) updateLogsKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.logs.filtering {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.logs.filtering = false
+			m.logs.filter = textInput{}
+		case tea.KeyEnter:
+			m.logs.filtering = false
+		case tea.KeyCtrlC:
+			return m.quit()
+		default:
+			m.logs.filter.update(msg)
+		}
+		m.logs.scrolled = false
+		return m, nil
+	}
+
+	if m.logs.saving {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.logs.saving = false
+		case tea.KeyEnter:
+			m.logs.saving = false
+			path := strings.TrimSpace(m.logs.savePath.value)
+			if path == "" {
+				return m, nil
+			}
+			return m, saveLogsCmd(expandHome(path), append(m.logs.lines[:len(m.logs.lines):len(m.logs.lines)], m.logs.held...))
+		case tea.KeyCtrlC:
+			return m.quit()
+		default:
+			m.logs.savePath.update(msg)
+		}
+		return m, nil
+	}
+
+	if next, cmd, ok := m.updateListKey(msg, (*Model).scrollLogs); ok {
+		return next, cmd
+	}
+	rows := m.logRows()
+	switch msg.String() {
+	case "pgup", "ctrl+u":
+		m.scrollLogs(-rows)
+	case "pgdown", "ctrl+d":
+		m.scrollLogs(rows)
+	case "g", "home":
+		m.scrollLogs(-len(m.logs.lines))
+	case "G", "end":
+		m.logs.scrolled = false
+	case " ", "p":
+		m.togglePauseLogs()
+	case "/":
+		m.logs.filtering = true
+	case "esc":
+		m.logs.filter = textInput{}
+		m.logs.scrolled = false
+	case "L":
+		return m, m.cycleLogLevel()
+	case "w":
+		m.logs.saving = true
+		m.logs.savePath = textInput{value: defaultLogPath()}
+	case "c":
+		m.logs.lines, m.logs.held = nil, nil
+		m.logs.scrolled, m.logs.offset = false, 0
+	}
+	return m, nil
+}
+
+func logLevelStyle(level string) lipgloss.Style {
+	switch level {
+	case "debug":
+		return logDebugStyle
+	case "warning":
+		return logWarningStyle
+	case "error":
+		return logErrorStyle
+	default:
+		return normalStyle
+	}
+}
+
+// logLevelLabel is the short, fixed-width label of a level.
+func logLevelLabel(level string) string {
+	switch level {
+	case "warning":
+		return "WARN "
+	case "error":
+		return "ERROR"
+	case "debug":
+		return "DEBUG"
+	default:
+		return fmt.Sprintf("%-5s", strings.ToUpper(level))
+	}
+}
+
+// logPrefixWidth is the width of the time and level in front of the text
+// of a log row.
+const logPrefixWidth = len(" 15:04:05 DEBUG ")
+
+// logNewlines flattens a multi-line log text onto its row.
+var logNewlines = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+func (m Model) logsView() string {
+	list := m.visibleLogs()
+
+	title := headerStyle.Render(" Logs") + helpStyle.Render(fmt.Sprintf("  level %s  %d/%d", m.logs.level, len(m.logs.lines), m.logBuffer()))
+	if m.logs.filter.value != "" {
+		title += helpStyle.Render(fmt.Sprintf("  %d matching", len(list)))
+	}
+	if m.logs.paused {
+		title += pendingMarkStyle.Render(fmt.Sprintf("  paused (%d new)", len(m.logs.held)))
+	} else if m.logs.scrolled {
+		title += helpStyle.Render("  [G] to follow")
+	}
+	lines := []string{title}
+
+	rows := m.logRows()
+	start := max(len(list)-rows, 0)
+	if m.logs.scrolled {
+		start = m.logs.offset
+	}
+	if len(list) == 0 {
+		if len(m.logs.lines) == 0 {
+			lines = append(lines, helpStyle.Render("   Waiting for log entries..."))
+		} else {
+			lines = append(lines, helpStyle.Render("   No matching entries"))
+		}
+	}
+	for i := start; i < len(list) && i < start+rows; i++ {
+		l := list[i]
+		style := logLevelStyle(l.level)
+		// One row per entry, cut at the edge of the terminal
+		text := logNewlines.Replace(l.text)
+		if m.Width > 0 {
+			text = fit(text, max(m.Width-logPrefixWidth, 1))
+		}
+		lines = append(lines, " "+helpStyle.Render(l.time.Format("15:04:05"))+" "+
+			style.Render(logLevelLabel(l.level))+" "+style.Render(text))
+	}
+
+	for len(lines) < m.bodyHeight()-minHelpRows {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n" + m.logsHelp()
+}
+
+func (m Model) logsHelp() string {
+	switch {
+	case m.logs.filtering:
+		return " " + m.logs.filter.view("/") + helpStyle.Render("  [Ent]Done [Esc]Clear")
+	case m.logs.saving:
+		return " " + m.logs.savePath.view("Save to: ") + helpStyle.Render("  [Ent]Save [Esc]Cancel")
+	case m.notice.text != "":
+		return m.noticeLine()
+	case m.logs.filter.value != "":
+		return m.helpLine(
+			[]helpKey{{"/" + m.logs.filter.value, 0}},
+			[]helpKey{{"[Esc]Clear", 0}},
+			[]helpKey{{"[Space]Pause", 1}, {"[L]Level", 2}, {"[w]Save", 3}},
+			[]helpKey{{"[Tab]Screens", 0}},
+			[]helpKey{{"[q]Quit", 0}},
+		)
+	}
+	return m.helpLine(
+		[]helpKey{{"[↑k]↑", 0}, {"[↓j]↓", 0}, {"[G]Follow", 3}},
+		[]helpKey{{"[Space]Pause", 1}, {"[/]Filter", 2}, {"[L]Level", 4}, {"[w]Save", 5}, {"[c]Clear", 6}},
+		[]helpKey{{"[Tab]Screens", 0}},
+		[]helpKey{{"[q]Quit", 0}},
+	)
+}
@@ -0,0 +1,207 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+// globalGroup is the group routing all traffic in global mode.
+const globalGroup = "GLOBAL"
+
+// configChange is a change of the controller's running configuration, with
+// the check that it took effect once the configuration is read back.
+type configChange struct {
+	desc    string // e.g. "mode global"
+	patch   map[string]interface{}
+	applied func(clash.RuntimeConfig) bool
+}
+
+type runtimeConfigLoadedMsg struct {
+	cfg *clash.RuntimeConfig
+	err error
+}
+
+type configPatchedMsg struct {
+	change configChange
+	cfg    *clash.RuntimeConfig // Read back after the change
+	err    error
+}
+
+func loadRuntimeConfigCmd(ctx context.Context, client clash.Backend) tea.Cmd {
+	return func() tea.Msg {
+		cfg, err := client.GetConfig(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return runtimeConfigLoadedMsg{cfg: cfg, err: err}
+	}
+}
+
+// patchConfigCmd applies change, then reads the configuration back so the
+// result can be checked.
+func patchConfigCmd(ctx context.Context, client clash.Backend, change configChange) tea.Cmd {
+	return func() tea.Msg {
+		err := client.PatchConfig(ctx, change.patch)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return configPatchedMsg{change: change, err: err}
+		}
+		cfg, err := client.GetConfig(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return configPatchedMsg{change: change, cfg: cfg, err: err}
+	}
+}
+
+// loadRuntimeConfigOnce fetches the running configuration after the first
+// successful load of the proxies.
+func (m *Model) loadRuntimeConfigOnce() tea.Cmd {
+	if m.runtimeRequested {
+		return nil
+	}
+	m.runtimeRequested = true
+	return loadRuntimeConfigCmd(m.context(), m.Client)
+}
+
+// mode returns the controller's routing mode, empty until it is known.
+func (m Model) mode() string {
+	if !m.runtimeLoaded {
+		return ""
+	}
+	return m.runtime.NormalizedMode()
+}
+
+// cycleMode switches the controller to the mode after the current one.
+func (m *Model) cycleMode() tea.Cmd {
+	if !m.runtimeLoaded {
+		return nil
+	}
+	next := clash.Modes[0]
+	for i, mode := range clash.Modes {
+		if mode == m.mode() {
+			next = clash.Modes[(i+1)%len(clash.Modes)]
+		}
+	}
+	change := configChange{
+		desc:    "mode " + next,
+		patch:   map[string]interface{}{"mode": next},
+		applied: func(c clash.RuntimeConfig) bool { return c.NormalizedMode() == next },
+	}
+	return tea.Batch(m.setNotice("Switching to "+change.desc+"...", false),
+		patchConfigCmd(m.context(), m.Client, change))
+}
+
+// setRuntimeConfig stores the running configuration and reorders the groups
+// for its mode.
+func (m *Model) setRuntimeConfig(cfg clash.RuntimeConfig) {
+	m.runtime = cfg
+	m.runtimeLoaded = true
+	m.setGroups(m.Groups)
+}
+
+// setGroups replaces the group list, ordered for the current mode, keeping
+// the current group selected while it exists.
+func (m *Model) setGroups(groups []string) {
+	current := ""
+	if m.CurrentIdx < len(m.Groups) {
+		current = m.Groups[m.CurrentIdx]
+	}
+	m.Groups = orderGroups(arrangeGroups(groups, m.Proxies, m.Config.UI.GroupOrder), m.mode())
+	for i, g := range m.Groups {
+		if g == current {
+			m.CurrentIdx = i
+		}
+	}
+	if m.CurrentIdx >= len(m.Groups) {
+		m.CurrentIdx = 0
+	}
+}
+
+// arrangeGroups sorts the groups by name, or in the order of the
+// controller's config, which it only reveals through the members of GLOBAL.
+// Groups GLOBAL does not list follow the others by name, so the order stays
+// the same across reloads whatever groups come and go.
+func arrangeGroups(groups []string, proxies map[string]clash.Proxy, order string) []string {
+	arranged := append([]string(nil), groups...)
+	sort.Strings(arranged)
+	if order == "name" {
+		return arranged
+	}
+	rank := make(map[string]int)
+	for i, name := range proxies[globalGroup].All {
+		rank[name] = i
+	}
+	sort.SliceStable(arranged, func(i, j int) bool {
+		ri, iok := rank[arranged[i]]
+		rj, jok := rank[arranged[j]]
+		if iok && jok {
+			return ri < rj
+		}
+		return iok && !jok
+	})
+	return arranged
+}
+
+// orderGroups moves the GLOBAL group to the top in global mode, where it is
+// the only group that matters, and leaves the order alone otherwise.
+func orderGroups(groups []string, mode string) []string {
+	if mode != "global" {
+		return groups
+	}
+	ordered := make([]string, 0, len(groups))
+	for _, g := range groups {
+		if g != globalGroup {
+			ordered = append(ordered, g)
+		}
+	}
+	if len(ordered) == len(groups) {
+		return ordered
+	}
+	return append([]string{globalGroup}, ordered...)
+}
+
+func (m Model) updateRuntimeConfigMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case runtimeConfigLoadedMsg:
+		m.runtimeErr = msg.err
+		if msg.err != nil {
+			// The header just goes without the mode
+			return m, nil
+		}
+		// The mode may have just added the header row
+		m.setRuntimeConfig(*msg.cfg)
+		m.syncLayout()
+		return m, nil
+
+	case configPatchedMsg:
+		if msg.err != nil {
+			return m, m.setNotice(fmt.Sprintf("Failed to set %s: %v", msg.change.desc, msg.err), true)
+		}
+		m.setRuntimeConfig(*msg.cfg)
+		m.adjustViewport()
+		if !msg.change.applied(*msg.cfg) {
+			return m, m.setNotice("The controller did not apply "+msg.change.desc, true)
+		}
+		return m, m.setNotice("Set "+msg.change.desc, false)
+	}
+	return m, nil
+}
+
+// modeLabel renders the mode for the header.
+func modeLabel(mode string) string {
+	switch mode {
+	case "global":
+		return latencyFairStyle.Render(mode)
+	case "direct":
+		return latencySlowStyle.Render(mode)
+	default:
+		return latencyGoodStyle.Render(mode)
+	}
+}
@@ -1,18 +1,19 @@
 package tui
 
 import (
+	"context"
 	"sort"
-	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/config"
 )
 
 type errMsg error
 
 func (m Model) Init() tea.Cmd {
-	return LoadProxiesCmd(m.Client)
+	return LoadProxiesCmd(m.loadCtx, m.Client)
 }
 
 type proxiesLoadedMsg struct {
@@ -34,24 +35,80 @@ var (
 	cursorStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("51")).Bold(true)
 	helpStyle            = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
 	separatorStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	latencyGoodStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	latencyFairStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("220"))
+	latencySlowStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	latencyDeadStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("160")).Bold(true)
+	latencyErrorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	pendingMarkStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Bold(true)
+	noticeStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("86"))
+	errorStyle           = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	trafficDownStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("45"))
+	trafficUpStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("213"))
+	logDebugStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	logWarningStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("220"))
+	logErrorStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("203")).Bold(true)
 )
 
 type Model struct {
-	Client          *clash.Client
-	Proxies         map[string]clash.Proxy
-	Groups          []string
-	CurrentIdx      int
-	Cursor          int
-	Loading         bool
-	Err             error
-	ViewportOffset  int
-	Height          int    // Terminal height
-	lastCursorProxy string // Track proxy name at cursor to restore position after reload
+	Config           config.Config
+	Client           clash.Backend
+	Proxies          map[string]clash.Proxy
+	Groups           []string
+	CurrentIdx       int
+	Cursor           int
+	Loading          bool
+	Err              error
+	ViewportOffset   int
+	Height           int                          // Terminal height
+	Width            int                          // Terminal width
+	Delays           map[string]clash.DelayResult // Latest latency test result per proxy name
+	lastCursorProxy  string                       // Track proxy name at cursor to restore position after reload
+	groupTest        groupTest                    // Latency test of a whole group in progress
+	groupSort        map[string]sortMode          // Sort mode chosen per group, overriding the config default
+	search           textInput                    // Filter of the current group's proxies
+	searching        bool                         // Whether the search input has focus
+	finder           finder                       // Fuzzy picker across all groups
+	pending          map[string]pendingSelection  // Proxy switches in flight per group
+	selectSeq        int                          // Id of the latest proxy switch
+	notice           notice                       // Status message shown instead of the help line
+	screen           screen                       // Page currently shown
+	conns            connectionsView              // State of the connections screen
+	closeOnSwitch    map[string]bool              // Close-connections-on-switch toggled per group at runtime
+	traffic          trafficMeter                 // Samples of the traffic stream shown in the header
+	logs             logsView                     // State of the logs screen
+	rules            rulesView                    // State of the rules screen
+	providers        providersView                // State of the proxy providers screen
+	ruleProviders    ruleProvidersView            // State of the rule providers screen
+	runtime          clash.RuntimeConfig          // Running configuration of the controller
+	runtimeLoaded    bool                         // Whether runtime has been read yet
+	runtimeRequested bool                         // Whether runtime has been asked for
+	runtimeErr       error                        // Error of the latest read of runtime
+	configScreen     configView                   // State of the config screen
+	drill            drillTrail                   // Way down into nested groups
+	detail           bool                         // Whether the detail panel of the proxy under the cursor is shown
+	profiles         profileSet                   // Controllers to switch between
+	restore          *profileState                // UI state to bring back once the proxies are loaded
+
+	// ctx is cancelled when the program quits, loadCtx when the proxy load
+	// in flight is superseded by a newer one
+	ctx        context.Context
+	cancel     context.CancelFunc
+	loadCtx    context.Context
+	loadCancel context.CancelFunc
 }
 
-func InitialModel() Model {
-	client := clash.NewClient("")
-	return Model{
+// InitialModel builds the model on the backend of the startup profile of
+// cfg: the mock backend in mock mode, a controller client otherwise.
+func InitialModel(cfg config.Config) Model {
+	return NewModel(cfg, connectProfile(cfg, cfg.ProfileList()[cfg.ActiveProfile()]))
+}
+
+// NewModel builds the model on top of any Backend implementation.
+func NewModel(cfg config.Config, client clash.Backend) Model {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := Model{
+		Config:          cfg,
 		Client:          client,
 		Proxies:         make(map[string]clash.Proxy),
 		Groups:          make([]string, 0),
@@ -61,56 +118,95 @@ func InitialModel() Model {
 		Err:             nil,
 		ViewportOffset:  0,
 		Height:          24,
+		Width:           80,
+		Delays:          make(map[string]clash.DelayResult),
 		lastCursorProxy: "",
+		profiles:        newProfileSet(cfg, client),
+		ctx:             ctx,
+		cancel:          cancel,
 	}
+	m.newLoadContext()
+	return m
 }
 
-func LoadProxiesCmd(client *clash.Client) tea.Cmd {
-	return func() tea.Msg {
-		proxies, err := client.GetProxies()
-		if err != nil {
-			return errMsg(err)
-		}
-
-		groups := make([]string, 0)
-		for name, proxy := range proxies.Proxies {
-			if proxy.Type == "Selector" || proxy.Type == "URLTest" {
-				groups = append(groups, name)
-			}
-		}
+// context returns the context every request of the model derives from.
+func (m *Model) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
 
-		// Sort groups alphabetically for consistent ordering
-		sort.Strings(groups)
+// newLoadContext cancels the proxy load in flight, if any, and returns a
+// fresh context for the next one.
+func (m *Model) newLoadContext() context.Context {
+	if m.loadCancel != nil {
+		m.loadCancel()
+	}
+	m.loadCtx, m.loadCancel = context.WithCancel(m.context())
+	return m.loadCtx
+}
 
-		return proxiesLoadedMsg{
-			proxies: proxies.Proxies,
-			groups:  groups,
-		}
+// quit cancels every request in flight and stops the program.
+func (m *Model) quit() (tea.Model, tea.Cmd) {
+	if m.cancel != nil {
+		m.cancel()
 	}
+	return *m, tea.Quit
 }
 
-func loadProxiesWithDelayCmd(client *clash.Client) tea.Cmd {
+// LoadProxiesCmd fetches the proxies. A load whose ctx has been cancelled
+// (superseded by a newer load, or the program quitting) yields no message.
+func LoadProxiesCmd(ctx context.Context, client clash.Backend) tea.Cmd {
 	return func() tea.Msg {
-		time.Sleep(200 * time.Millisecond)
-
-		proxies, err := client.GetProxies()
+		proxies, err := client.GetProxies(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
 		if err != nil {
 			return errMsg(err)
 		}
+		return newProxiesLoadedMsg(proxies.Proxies)
+	}
+}
 
-		groups := make([]string, 0)
-		for name, proxy := range proxies.Proxies {
-			if proxy.Type == "Selector" || proxy.Type == "URLTest" {
-				groups = append(groups, name)
-			}
-		}
+// groupTypes lists the proxy types shown as groups, and whether a member can
+// be picked by hand. URLTest and Fallback groups take a pick as a fixed
+// override of their own choice; LoadBalance and Relay groups use all their
+// members and are read-only.
+var groupTypes = map[string]bool{
+	"Selector":    true,
+	"URLTest":     true,
+	"Fallback":    true,
+	"LoadBalance": false,
+	"Relay":       false,
+}
 
-		// Sort groups alphabetically for consistent ordering
-		sort.Strings(groups)
+// isGroup reports whether the proxy is a group listed on the proxies screen.
+func isGroup(proxy clash.Proxy) bool {
+	_, ok := groupTypes[proxy.Type]
+	return ok
+}
+
+// isSelectable reports whether a member of the group can be made active.
+func isSelectable(proxy clash.Proxy) bool {
+	return groupTypes[proxy.Type]
+}
 
-		return proxiesLoadedMsg{
-			proxies: proxies.Proxies,
-			groups:  groups,
+func newProxiesLoadedMsg(proxies map[string]clash.Proxy) proxiesLoadedMsg {
+	groups := make([]string, 0)
+	for name, proxy := range proxies {
+		if isGroup(proxy) && !proxy.Hidden {
+			groups = append(groups, name)
 		}
 	}
+
+	// Sort by name so the message does not depend on the map's random order;
+	// setGroups then puts them in the configured order
+	sort.Strings(groups)
+
+	return proxiesLoadedMsg{
+		proxies: proxies,
+		groups:  groups,
+	}
 }
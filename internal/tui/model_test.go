@@ -1,11 +1,19 @@
 package tui
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/config"
 )
 
 func TestCursorMovement(t *testing.T) {
@@ -169,6 +177,32 @@ func TestHelpAtBottomSmallTerminal(t *testing.T) {
 	}
 }
 
+func TestHelpFitsWidth(t *testing.T) {
+	m := NewModel(config.Default(), clash.NewMock(nil))
+	m.Height = 24
+	defer m.cancel()
+	updated, _ := m.Update(LoadProxiesCmd(m.loadCtx, m.Client)())
+	m = updated.(Model)
+
+	for _, width := range []int{60, 80, 100} {
+		m.Width = width
+		lines := strings.Split(m.View(), "\n")
+		help := lines[len(lines)-1]
+		if lipgloss.Width(help) > width {
+			t.Errorf("Width %d: help line is %d columns wide: %q", width, lipgloss.Width(help), help)
+		}
+		for _, key := range []string{"[Ent]Select", "[Tab]Screens", "[q]Quit"} {
+			if !strings.Contains(help, key) {
+				t.Errorf("Width %d: expected %s in the help line, got %q", width, key, help)
+			}
+		}
+	}
+	m.Width = 80
+	if help := m.View(); strings.Contains(help, "[m]Mode") {
+		t.Errorf("Expected the least important keys to go first at 80 columns")
+	}
+}
+
 func TestLayoutWithMultipleGroups(t *testing.T) {
 	m := Model{
 		Proxies: map[string]clash.Proxy{
@@ -233,3 +267,1286 @@ func TestLayoutWithMultipleGroups(t *testing.T) {
 		t.Errorf("Expected group 'Auto' to be in output")
 	}
 }
+
+func TestSelectWithMockBackend(t *testing.T) {
+	backend := clash.NewMock(map[string]clash.Proxy{
+		"Proxy": {
+			Name: "Proxy",
+			Type: "Selector",
+			Now:  "Proxy-1",
+			All:  []string{"Proxy-1", "Proxy-2", "Proxy-3"},
+		},
+	})
+	m := NewModel(config.Default(), backend)
+
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	if m.Loading || len(m.Groups) != 1 {
+		t.Fatalf("Expected groups to be loaded from the backend, got %v", m.Groups)
+	}
+
+	m.Cursor = 2
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatalf("Expected a reload command after selecting a proxy")
+	}
+	newModel, _ = newModel.(Model).Update(cmd())
+	m = newModel.(Model)
+	if now := m.Proxies["Proxy"].Now; now != "Proxy-3" {
+		t.Errorf("Expected Proxy-3 to be selected in the backend, got %q", now)
+	}
+}
+
+func TestReloadCancelsLoadInFlight(t *testing.T) {
+	m := NewModel(config.Default(), clash.NewMock(nil))
+	first := m.loadCtx
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = newModel.(Model)
+	if first.Err() == nil {
+		t.Errorf("Expected pressing r while loading to cancel the previous load")
+	}
+	if cmd == nil {
+		t.Fatalf("Expected a new load command")
+	}
+	if msg := LoadProxiesCmd(first, m.Client)(); msg != nil {
+		t.Errorf("Expected a cancelled load to yield no message, got %T", msg)
+	}
+
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+	if cmd == nil || m.loadCtx.Err() == nil {
+		t.Errorf("Expected quitting to cancel the load in flight")
+	}
+}
+
+func TestGroupDelayTest(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	m = newModel.(Model)
+	if !m.groupTest.running() || cmd == nil {
+		t.Fatalf("Expected t to start a group test")
+	}
+
+	group := m.Proxies[m.Groups[m.CurrentIdx]]
+	for cmd != nil {
+		newModel, cmd = m.Update(cmd())
+		m = newModel.(Model)
+	}
+	if m.groupTest.running() {
+		t.Errorf("Expected the group test to finish")
+	}
+	for _, name := range group.All {
+		if _, ok := m.Delays[name]; !ok {
+			t.Errorf("Expected a delay result for %s", name)
+		}
+	}
+
+	// Starting and cancelling a run
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if m.groupTest.running() {
+		t.Errorf("Expected Esc to cancel the group test")
+	}
+	// Results of the cancelled run are dropped
+	for cmd != nil {
+		newModel, cmd = m.Update(cmd())
+		m = newModel.(Model)
+	}
+}
+
+func TestLatencyColumn(t *testing.T) {
+	now := time.Now()
+	m := Model{
+		Proxies: map[string]clash.Proxy{
+			"Proxy": {
+				Name: "Proxy",
+				Type: "Selector",
+				Now:  "Fast",
+				All:  []string{"Fast", "Dead", "Untested-Long-Name"},
+			},
+			"Fast": {
+				Name:    "Fast",
+				History: []clash.ProxyHistory{{Time: now.Add(-2 * time.Minute).Format(time.RFC3339Nano), Delay: 120}},
+			},
+			"Dead": {
+				Name:    "Dead",
+				History: []clash.ProxyHistory{{Time: now.Add(-time.Hour).Format(time.RFC3339Nano), Delay: 0}},
+			},
+		},
+		Delays: map[string]clash.DelayResult{
+			// Older than the controller's history, must not win
+			"Fast": {Proxy: "Fast", Status: clash.DelayOK, Delay: 999, Time: now.Add(-time.Hour)},
+		},
+		Groups:  []string{"Proxy"},
+		Loading: false,
+		Height:  24,
+	}
+	out := m.View()
+	lines := strings.Split(out, "\n")
+	if !strings.Contains(lines[1], "120ms") || !strings.Contains(lines[1], "2m") {
+		t.Errorf("Expected latency and age from history, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "timeout") {
+		t.Errorf("Expected a timeout marker for a zero delay, got %q", lines[2])
+	}
+	if lipgloss.Width(lines[1]) != lipgloss.Width(lines[2]) {
+		t.Errorf("Expected the latency column to be right-aligned:\n%s\n%s", lines[1], lines[2])
+	}
+
+	m.Delays["Dead"] = clash.DelayResult{Proxy: "Dead", Status: clash.DelayOK, Delay: 300, Time: now}
+	if l, _ := m.latencyOf("Dead"); l.delay != 300 {
+		t.Errorf("Expected a fresh test result to override older history, got %+v", l)
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	ordered := []string{"HK-01", "HK-2", "HK-10", "HK-10a", "JP-1", "Proxy-9", "Proxy-010", "Proxy-11"}
+	for i := 0; i+1 < len(ordered); i++ {
+		if !naturalLess(ordered[i], ordered[i+1]) {
+			t.Errorf("Expected %q < %q", ordered[i], ordered[i+1])
+		}
+		if naturalLess(ordered[i+1], ordered[i]) {
+			t.Errorf("Expected %q > %q", ordered[i+1], ordered[i])
+		}
+	}
+}
+
+func TestSortKeepsCursor(t *testing.T) {
+	m := Model{
+		Proxies: map[string]clash.Proxy{
+			"Proxy": {
+				Name: "Proxy",
+				Type: "Selector",
+				Now:  "Node-10",
+				All:  []string{"Node-10", "Node-9", "Node-2", "Node-1"},
+			},
+			"Auto": {
+				Name: "Auto",
+				Type: "URLTest",
+				Now:  "Auto-1",
+				All:  []string{"Auto-1"},
+			},
+		},
+		Delays: map[string]clash.DelayResult{
+			"Node-10": {Proxy: "Node-10", Status: clash.DelayOK, Delay: 300, Time: time.Now()},
+			"Node-9":  {Proxy: "Node-9", Status: clash.DelayTimeout, Time: time.Now()},
+			"Node-2":  {Proxy: "Node-2", Status: clash.DelayOK, Delay: 100, Time: time.Now()},
+		},
+		Groups:          []string{"Proxy", "Auto"},
+		Cursor:          1,
+		lastCursorProxy: "Node-9",
+		Height:          24,
+	}
+
+	expected := [][]string{
+		{"Node-1", "Node-2", "Node-9", "Node-10"}, // name
+		{"Node-2", "Node-10", "Node-1", "Node-9"}, // latency
+		{"Node-10", "Node-2", "Node-1", "Node-9"}, // alive
+		{"Node-10", "Node-9", "Node-2", "Node-1"}, // original
+	}
+	for _, want := range expected {
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+		m = newModel.(Model)
+		got := m.members("Proxy")
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected order %v, got %v", want, got)
+		}
+		if got[m.Cursor] != "Node-9" {
+			t.Errorf("Expected cursor to stay on Node-9 after re-sort, got %s", got[m.Cursor])
+		}
+	}
+
+	// The sort mode is remembered per group
+	m.groupSort["Proxy"] = sortName
+	newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	newModel, _ = newModel.(Model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'h'}})
+	m = newModel.(Model)
+	if m.sortModeOf("Proxy") != sortName || m.sortModeOf("Auto") != sortOriginal {
+		t.Errorf("Expected sort modes to be kept per group")
+	}
+	if m.members("Proxy")[m.Cursor] != "Node-10" {
+		t.Errorf("Expected cursor on the active proxy after switching groups")
+	}
+}
+
+func TestSearchFilter(t *testing.T) {
+	backend := clash.NewMock(map[string]clash.Proxy{
+		"Proxy": {
+			Name: "Proxy",
+			Type: "Selector",
+			Now:  "JP-01",
+			All:  []string{"JP-01", "HK-01", "US-01", "hk-02", "HK-03"},
+		},
+	})
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	for _, r := range "/hk" {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+	if !m.searching {
+		t.Fatalf("Expected / to start search mode")
+	}
+	if got := strings.Join(m.members("Proxy"), ","); got != "HK-01,hk-02,HK-03" {
+		t.Errorf("Expected case-insensitive substring matches, got %s", got)
+	}
+	if !strings.Contains(m.View(), "/hk") {
+		t.Errorf("Expected the search input in the view")
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = newModel.(Model)
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if m.searching || cmd == nil {
+		t.Fatalf("Expected Enter to leave search mode and select the match")
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if now := m.Proxies["Proxy"].Now; now != "hk-02" {
+		t.Errorf("Expected hk-02 to be selected, got %s", now)
+	}
+
+	// n/N wrap around the matches
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	newModel, _ = newModel.(Model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = newModel.(Model)
+	if _, p, _ := m.cursorProxy(); p != "HK-01" {
+		t.Errorf("Expected n to wrap around to HK-01, got %s", p)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'N'}})
+	m = newModel.(Model)
+	if _, p, _ := m.cursorProxy(); p != "HK-03" {
+		t.Errorf("Expected N to wrap back to HK-03, got %s", p)
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if len(m.members("Proxy")) != 5 {
+		t.Errorf("Expected Esc to clear the filter")
+	}
+	if _, p, _ := m.cursorProxy(); p != "HK-03" {
+		t.Errorf("Expected cursor to stay on HK-03 after clearing the filter, got %s", p)
+	}
+}
+
+func TestFinder(t *testing.T) {
+	backend := clash.NewMock(map[string]clash.Proxy{
+		"Proxy": {
+			Name: "Proxy",
+			Type: "Selector",
+			Now:  "JP-01",
+			All:  []string{"JP-01", "HK-01", "HK-10"},
+		},
+		"Streaming": {
+			Name: "Streaming",
+			Type: "Selector",
+			Now:  "US-01",
+			All:  []string{"US-01", "HK-01"},
+		},
+	})
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlP})
+	m = newModel.(Model)
+	for _, r := range "hk 01" {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+	if len(m.finder.results) != 2 {
+		t.Fatalf("Expected HK-01 in both groups, got %+v", m.finder.results)
+	}
+	for _, r := range m.finder.results {
+		if r.proxy != "HK-01" {
+			t.Errorf("Unexpected match %+v", r)
+		}
+	}
+
+	// Jump to the match in "Streaming" and select it
+	var idx int
+	for i, r := range m.finder.results {
+		if r.group == "Streaming" {
+			idx = i
+		}
+	}
+	for i := 0; i < idx; i++ {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m = newModel.(Model)
+	}
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	m = newModel.(Model)
+	if m.finder.open || m.Groups[m.CurrentIdx] != "Streaming" {
+		t.Fatalf("Expected to jump to Streaming, got group %s", m.Groups[m.CurrentIdx])
+	}
+	if _, p, _ := m.cursorProxy(); p != "HK-01" {
+		t.Errorf("Expected the cursor on HK-01, got %s", p)
+	}
+	if cmd == nil {
+		t.Fatalf("Expected Alt+Enter to select the proxy")
+	}
+	newModel, _ = m.Update(cmd())
+	if now := newModel.(Model).Proxies["Streaming"].Now; now != "HK-01" {
+		t.Errorf("Expected HK-01 to be active in Streaming, got %s", now)
+	}
+}
+
+// failingBackend rejects every proxy switch.
+type failingBackend struct {
+	*clash.Mock
+}
+
+func (failingBackend) SelectProxy(ctx context.Context, groupName, proxyName string) error {
+	return errors.New("boom")
+}
+
+func TestOptimisticSelection(t *testing.T) {
+	proxies := map[string]clash.Proxy{
+		"Proxy": {
+			Name: "Proxy",
+			Type: "Selector",
+			Now:  "Proxy-1",
+			All:  []string{"Proxy-1", "Proxy-2", "Proxy-3"},
+		},
+	}
+
+	for _, fail := range []bool{false, true} {
+		var backend clash.Backend = clash.NewMock(proxies)
+		if fail {
+			backend = failingBackend{clash.NewMock(proxies)}
+		}
+		m := NewModel(config.Default(), backend)
+		newModel, _ := m.Update(m.Init()())
+		m = newModel.(Model)
+
+		m.Cursor = 1
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m = newModel.(Model)
+		if m.Proxies["Proxy"].Now != "Proxy-2" || !m.isPending("Proxy") {
+			t.Fatalf("Expected Proxy-2 to be shown as active and pending right away")
+		}
+		if !strings.Contains(m.View(), "~") {
+			t.Errorf("Expected a pending marker in the view")
+		}
+
+		newModel, _ = m.Update(cmd())
+		m = newModel.(Model)
+		if m.isPending("Proxy") {
+			t.Errorf("Expected the switch to be settled")
+		}
+		if fail {
+			if m.Proxies["Proxy"].Now != "Proxy-1" {
+				t.Errorf("Expected rollback to Proxy-1, got %s", m.Proxies["Proxy"].Now)
+			}
+			if !m.notice.err || !strings.Contains(m.View(), "boom") {
+				t.Errorf("Expected an error notice, got %+v", m.notice)
+			}
+		} else if m.Proxies["Proxy"].Now != "Proxy-2" {
+			t.Errorf("Expected Proxy-2 to be confirmed, got %s", m.Proxies["Proxy"].Now)
+		}
+	}
+}
+
+func TestConnectionsScreen(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = newModel.(Model)
+	if m.screen != screenConnections {
+		t.Fatalf("Expected Tab to open the connections screen")
+	}
+	newModel, _ = m.Update(loadConnectionsCmd(m.context(), backend)())
+	m = newModel.(Model)
+	total := len(m.conns.list)
+	if total == 0 || !strings.Contains(m.View(), "github.com") {
+		t.Fatalf("Expected connections to be listed, got:\n%s", m.View())
+	}
+
+	// Columns shrink to the terminal, the chain going before the traffic
+	for _, width := range []int{140, 100, 80} {
+		m.Width = width
+		lines := strings.Split(m.View(), "\n")
+		for _, line := range lines {
+			if w := lipgloss.Width(line); w > width {
+				t.Errorf("Width %d: line is %d columns wide: %q", width, w, line)
+			}
+		}
+		if header := lines[2]; !strings.Contains(header, "Age") || strings.Contains(header, "Chain") != (width >= 100) {
+			t.Errorf("Width %d: unexpected column header %q", width, header)
+		}
+		if help := lines[len(lines)-1]; !strings.Contains(help, "[Tab]Screens") || !strings.Contains(help, "[q]Quit") {
+			t.Errorf("Width %d: expected [Tab]Screens and [q]Quit in the help line, got %q", width, help)
+		}
+	}
+	m.Width = 0
+
+	// Filter, then close the only match
+	for _, r := range "/youtube" {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if got := m.visibleConnections(); len(got) != 1 || got[0].Metadata.Host != "www.youtube.com" {
+		t.Fatalf("Expected the filter to leave the youtube connection, got %+v", got)
+	}
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = newModel.(Model)
+	newModel, cmd = m.Update(cmd())
+	m = newModel.(Model)
+	if !strings.Contains(m.notice.text, "Closed 1 connection") {
+		t.Errorf("Expected a notice about the closed connection, got %q", m.notice.text)
+	}
+	resp, _ := backend.GetConnections(context.Background())
+	if len(resp.Connections) != total-1 {
+		t.Errorf("Expected one connection to be closed, %d left", len(resp.Connections))
+	}
+
+	// Closing all asks for confirmation first
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'X'}})
+	m = newModel.(Model)
+	if cmd != nil || !m.conns.confirmCloseAll {
+		t.Fatalf("Expected X to ask for confirmation")
+	}
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = newModel.(Model)
+	m.Update(cmd())
+	resp, _ = backend.GetConnections(context.Background())
+	if len(resp.Connections) != 0 {
+		t.Errorf("Expected all connections to be closed, %d left", len(resp.Connections))
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	if newModel.(Model).screen != screenProxies {
+		t.Errorf("Expected Shift+Tab to go back to the proxies screen")
+	}
+}
+
+func TestCloseConnectionsOnSwitch(t *testing.T) {
+	backend := clash.NewMock(nil)
+	cfg := config.Default()
+	cfg.Connections.CloseOnSwitch = []string{"Proxy Group A"}
+	m := NewModel(cfg, backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	if m.Groups[m.CurrentIdx] != "Proxy Group A" {
+		t.Fatalf("Expected to start on Proxy Group A")
+	}
+
+	m.Cursor = 1
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	newModel, cmd = newModel.(Model).Update(cmd())
+	m = newModel.(Model)
+	if cmd == nil {
+		t.Fatalf("Expected connections to be closed after the switch")
+	}
+	msg, ok := cmd().(staleConnectionsClosedMsg)
+	if !ok {
+		t.Fatalf("Expected the connections of the group to be closed")
+	}
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	if !strings.Contains(m.notice.text, "closed 3 connections") {
+		t.Errorf("Expected a summary of closed connections, got %q", m.notice.text)
+	}
+	resp, _ := backend.GetConnections(context.Background())
+	for _, c := range resp.Connections {
+		if _, ok := c.Through("Proxy Group A"); ok {
+			t.Errorf("Expected connection %s through the switched group to be closed", c.ID)
+		}
+	}
+
+	// Toggling at runtime overrides the config
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	if newModel.(Model).closesOnSwitch("Proxy Group A") {
+		t.Errorf("Expected c to turn closing connections off for the group")
+	}
+}
+
+func TestTrafficHeader(t *testing.T) {
+	cfg := config.Default()
+	m := NewModel(cfg, clash.NewMock(nil))
+	m.Height = 12
+	defer m.cancel()
+
+	msg := LoadProxiesCmd(m.loadCtx, m.Client)()
+	updated, cmd := m.Update(msg)
+	m = updated.(Model)
+	if cmd == nil {
+		t.Fatal("Expected the first load to subscribe to the traffic stream")
+	}
+	// The first load also reads the running configuration
+	var sample trafficMsg
+	for _, c := range cmd().(tea.BatchMsg) {
+		if msg, ok := c().(trafficMsg); ok {
+			sample = msg
+		}
+	}
+	if sample.stream == nil {
+		t.Fatal("Expected a traffic sample from the mock stream")
+	}
+	updated, cmd = m.Update(sample)
+	m = updated.(Model)
+	if cmd == nil {
+		t.Errorf("Expected to keep waiting for samples")
+	}
+
+	// A reload must not subscribe a second time
+	if _, cmd := m.Update(msg); cmd != nil {
+		t.Errorf("Expected no second traffic subscription on reload")
+	}
+
+	lines := strings.Split(m.View(), "\n")
+	if !strings.Contains(lines[0], "↓") || strings.Contains(lines[0], "--") {
+		t.Errorf("Expected traffic rates in the first line, got %q", lines[0])
+	}
+	if len(lines) != m.Height {
+		t.Errorf("Expected the header to fit in %d lines, got %d", m.Height, len(lines))
+	}
+	if !strings.Contains(lines[len(lines)-1], "[q]Quit") {
+		t.Errorf("Help message not on last line, got: %q", lines[len(lines)-1])
+	}
+
+	// The meter turns to "--" once the stream goes quiet
+	defer func(d time.Duration) { trafficStaleAfter = d }(trafficStaleAfter)
+	trafficStaleAfter = 50 * time.Millisecond
+	updated, cmd = m.Update(sample)
+	m = updated.(Model)
+	updated, _ = m.Update(waitMsg[trafficStaleMsg](t, cmd))
+	if header := strings.Split(updated.(Model).View(), "\n")[0]; !strings.Contains(header, "↓ --") {
+		t.Errorf("Expected the rates to go stale, got %q", header)
+	}
+}
+
+func TestHeaderKeepsCursorVisible(t *testing.T) {
+	// The header appears with the first load; the cursor, placed on the
+	// active proxy at the end of the group, must stay on screen below it
+	for height := 6; height <= 16; height++ {
+		client := clash.NewMock(nil)
+		if err := client.SelectProxy(context.Background(), "Proxy Group A", "Proxy-7"); err != nil {
+			t.Fatal(err)
+		}
+		m := NewModel(config.Default(), client)
+		m.Height = height
+		updated, _ := m.Update(LoadProxiesCmd(m.loadCtx, m.Client)())
+		m = updated.(Model)
+		m.cancel()
+
+		_, name, _ := m.cursorProxy()
+		var found bool
+		for _, line := range strings.Split(m.View(), "\n") {
+			if strings.Contains(line, "> ") && strings.Contains(line, name) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Height %d: expected the cursor on %s to be visible, got:\n%s", height, name, m.View())
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]int64{0, 5, 10}, 5); got != "  ▁▄█" {
+		t.Errorf("Unexpected sparkline %q", got)
+	}
+	if got := sparkline([]int64{0, 0}, 2); got != "▁▁" {
+		t.Errorf("Expected flat sparkline for no traffic, got %q", got)
+	}
+}
+
+func TestLogsScreen(t *testing.T) {
+	cfg := config.Default()
+	cfg.Logs.Buffer = 3
+	m := NewModel(cfg, clash.NewMock(nil))
+	m.Height = 10
+	defer m.cancel()
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	var cmd tea.Cmd
+	for m.screen != screenLogs {
+		newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = newModel.(Model)
+	}
+	if cmd == nil {
+		t.Fatalf("Expected opening the logs screen to subscribe to the stream")
+	}
+	stream := m.logs.stream
+	entry := func(level, text string) logEntryMsg {
+		return logEntryMsg{line: logLine{time: time.Now(), level: level, text: text}, stream: stream}
+	}
+	for _, e := range []logEntryMsg{entry("info", "one"), entry("warning", "two"), entry("info", "three"), entry("error", "four")} {
+		newModel, _ = m.Update(e)
+		m = newModel.(Model)
+	}
+	if len(m.logs.lines) != 3 || m.logs.lines[0].text != "two" {
+		t.Fatalf("Expected the buffer to keep the 3 newest lines, got %+v", m.logs.lines)
+	}
+	if lines := strings.Split(m.View(), "\n"); len(lines) != m.Height || !strings.Contains(m.View(), "four") {
+		t.Errorf("Expected %d lines showing the newest entry, got:\n%s", m.Height, m.View())
+	}
+
+	// Every entry takes one row cut to the terminal, with the help line
+	// keeping its way out
+	m.Width = 80
+	newModel, _ = m.Update(entry("info", "first line\nsecond line "+strings.Repeat("x", 100)))
+	m = newModel.(Model)
+	lines := strings.Split(m.View(), "\n")
+	if len(lines) != m.Height || !strings.Contains(m.View(), "first line second line") {
+		t.Errorf("Expected the entry on a single row, got:\n%s", m.View())
+	}
+	for _, line := range lines {
+		if w := lipgloss.Width(line); w > m.Width {
+			t.Errorf("Line is %d columns wide: %q", w, line)
+		}
+	}
+	if help := lines[len(lines)-1]; !strings.Contains(help, "[Tab]Screens") || !strings.Contains(help, "[q]Quit") {
+		t.Errorf("Expected [Tab]Screens and [q]Quit in the help line, got %q", help)
+	}
+	m.Width = 0
+
+	// Paused lines are held back until resumed
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m = newModel.(Model)
+	newModel, _ = m.Update(entry("info", "five"))
+	m = newModel.(Model)
+	if strings.Contains(m.View(), "five") || !strings.Contains(m.View(), "paused (1 new)") {
+		t.Errorf("Expected the new line to be held while paused, got:\n%s", m.View())
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m = newModel.(Model)
+	if !strings.Contains(m.View(), "five") {
+		t.Errorf("Expected held lines to show after resuming")
+	}
+
+	for _, r := range "/err" {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+	if got := m.visibleLogs(); len(got) != 1 || got[0].text != "four" {
+		t.Errorf("Expected the filter to match the error, got %+v", got)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+
+	// Changing the level replaces the stream; entries of the old one are ignored
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'L'}})
+	m = newModel.(Model)
+	if m.logs.level != "warning" || m.logs.stream == stream {
+		t.Errorf("Expected L to resubscribe at the next level, got %q", m.logs.level)
+	}
+	if _, cmd := m.Update(entry("info", "stale")); cmd != nil {
+		t.Errorf("Expected entries of the old stream to be dropped")
+	}
+
+	path := filepath.Join(t.TempDir(), "logs.txt")
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	m = newModel.(Model)
+	m.logs.savePath = textInput{value: path}
+	_, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if saved, ok := cmd().(logsSavedMsg); !ok || saved.err != nil || saved.count != 3 {
+		t.Fatalf("Expected the buffer to be saved, got %+v", saved)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "ERROR   four") {
+		t.Errorf("Unexpected saved logs:\n%s", data)
+	}
+}
+
+func TestRulesScreen(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	var cmd tea.Cmd
+	for m.screen != screenRules {
+		newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = newModel.(Model)
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if !strings.Contains(m.View(), "githubusercontent.com") {
+		t.Fatalf("Expected rules to be listed, got:\n%s", m.View())
+	}
+
+	for _, r := range "/youtube" {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = newModel.(Model)
+	}
+	if got := m.visibleRules(); len(got) != 1 || got[0].index != 2 {
+		t.Fatalf("Expected the search to leave the youtube rule, got %+v", got)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if m.screen != screenProxies || m.Groups[m.CurrentIdx] != "Proxy Group B" {
+		t.Errorf("Expected Enter to jump to the rule's target group, got screen %d group %q", m.screen, m.Groups[m.CurrentIdx])
+	}
+
+	// A target that is not a group lands on a group containing it, with the
+	// cursor on the target
+	m.screen = screenRules
+	m.rules.list = []clash.Rule{{Type: "Match", Proxy: "Auto-4"}}
+	m.rules.filter = textInput{}
+	m.syncRuleCursor()
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if _, proxy, _ := m.cursorProxy(); m.screen != screenProxies || m.Groups[m.CurrentIdx] != "Proxy Group B" || proxy != "Auto-4" {
+		t.Errorf("Expected Enter to land on Auto-4 in Proxy Group B, got screen %d group %q proxy %q", m.screen, m.Groups[m.CurrentIdx], proxy)
+	}
+
+	// A target outside every group is reported
+	m.screen = screenRules
+	m.rules.list = []clash.Rule{{Type: "Match", Proxy: "Elsewhere"}}
+	m.rules.filter = textInput{}
+	m.syncRuleCursor()
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if m.screen != screenRules || cmd == nil || !strings.Contains(m.notice.text, "Elsewhere") {
+		t.Errorf("Expected a notice for a target outside every group, got %q", m.notice.text)
+	}
+}
+
+func TestProvidersScreen(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	var cmd tea.Cmd
+	for m.screen != screenProviders {
+		newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = newModel.(Model)
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if len(m.providers.list) != 2 || m.providers.list[0].Name != "local-nodes" {
+		t.Fatalf("Expected the providers sorted by name, got %+v", m.providers.list)
+	}
+	if view := m.View(); !strings.Contains(view, "sample-subscription") || !strings.Contains(view, "alive") {
+		t.Errorf("Expected providers with their health, got:\n%s", view)
+	}
+
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newModel.(Model)
+	if cmd == nil || !strings.Contains(m.View(), "updating...") {
+		t.Fatalf("Expected u to update the provider under the cursor")
+	}
+	if _, again := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}}); again != nil {
+		t.Errorf("Expected no second update while one is running")
+	}
+
+	// A finished update refreshes the providers and the groups
+	newModel, cmd = m.Update(cmd())
+	m = newModel.(Model)
+	if _, busy := m.providers.busy["local-nodes"]; busy || !strings.Contains(m.notice.text, "Updated local-nodes") {
+		t.Errorf("Expected the update to finish with a notice, got %q", m.notice.text)
+	}
+	waitMsg[proxiesLoadedMsg](t, cmd)
+}
+
+func TestRuleProvidersScreen(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	var cmd tea.Cmd
+	for m.screen != screenRuleProviders {
+		newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = newModel.(Model)
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if view := m.View(); !strings.Contains(view, "cn-ip") || !strings.Contains(view, "8421") {
+		t.Fatalf("Expected rule providers with their rule count, got:\n%s", view)
+	}
+
+	// Refresh all: one request per provider, summed up once all finished
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'U'}})
+	m = newModel.(Model)
+	batch := cmd().(tea.BatchMsg)
+	if len(batch) != 3 || len(m.ruleProviders.busy) != 3 {
+		t.Fatalf("Expected every provider to be refreshed, got %d requests", len(batch))
+	}
+	for _, c := range batch {
+		newModel, _ = m.Update(c())
+		m = newModel.(Model)
+	}
+	if len(m.ruleProviders.busy) != 0 || m.notice.text != "Refreshed 3 rule providers" {
+		t.Errorf("Expected a single summary notice, got %q", m.notice.text)
+	}
+	resp, _ := backend.GetRuleProviders(context.Background())
+	if age := time.Since(resp.Providers["ads"].UpdatedAt); age > time.Minute {
+		t.Errorf("Expected the provider to be refreshed, updated %v ago", age)
+	}
+
+	// The last refresh of a batch failing still sums the batch up
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'U'}})
+	m = newModel.(Model)
+	for _, msg := range []ruleProviderRefreshedMsg{
+		{name: "ads"}, {name: "cn-ip"}, {name: "private", err: errors.New("boom")},
+	} {
+		newModel, _ = m.Update(msg)
+		m = newModel.(Model)
+	}
+	if m.notice.text != "Refreshed 2, 1 failed" || !m.notice.err {
+		t.Errorf("Expected a summary counting the failure, got %q", m.notice.text)
+	}
+
+	// ... and the next refresh starts counting from zero
+	newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	m = newModel.(Model)
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if m.notice.text != "Refreshed ads" {
+		t.Errorf("Expected a notice for the single refresh, got %q", m.notice.text)
+	}
+}
+
+func TestGroupTypes(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	show := func(group string) {
+		t.Helper()
+		for i, g := range m.Groups {
+			if g == group {
+				m.CurrentIdx = i
+				m.Cursor = 0
+				return
+			}
+		}
+		t.Fatalf("Expected %s to be listed, got %q", group, m.Groups)
+	}
+
+	// LoadBalance and Relay groups are listed but read-only
+	show("Upload Pool")
+	if !strings.Contains(m.View(), "Upload Pool (LoadBalance)") {
+		t.Errorf("Expected the group's type in the view, got:\n%s", m.View())
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if len(m.pending) != 0 || !strings.Contains(m.notice.text, "LoadBalance group") {
+		t.Errorf("Expected Enter on a LoadBalance group to only show a notice, got %q", m.notice.text)
+	}
+
+	// A relay keeps its chain in order whatever the sort
+	show("Relay Chain")
+	m.cycleSort()
+	if got := m.members("Relay Chain"); got[0] != "Proxy-1" || got[1] != "Auto-1" {
+		t.Errorf("Expected the relay's members in chain order, got %q", got)
+	}
+
+	// A Fallback group takes a fixed pick
+	show("Streaming")
+	m.Cursor = 1
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	newModel, _ = m.Update(waitMsg[selectResultMsg](t, cmd))
+	m = newModel.(Model)
+	if now := m.Proxies["Streaming"].Now; now != "Proxy-3" {
+		t.Errorf("Expected Proxy-3 to be picked in the Fallback group, got %q", now)
+	}
+}
+
+func TestDrillDown(t *testing.T) {
+	backend := clash.NewMock(map[string]clash.Proxy{
+		"Proxy":   {Name: "Proxy", Type: "Selector", Now: "Auto-HK", All: []string{"DIRECT", "Auto-HK"}},
+		"Auto-HK": {Name: "Auto-HK", Type: "URLTest", Now: "HK-03", All: []string{"HK-01", "HK-03"}},
+		"Loop-A":  {Name: "Loop-A", Type: "Selector", Now: "Loop-B", All: []string{"Loop-B"}},
+		"Loop-B":  {Name: "Loop-B", Type: "Selector", Now: "Loop-A", All: []string{"Loop-A"}},
+	})
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	m.jumpTo("Proxy", "")
+
+	view := m.View()
+	if !strings.Contains(view, "→ Auto-HK → HK-03") {
+		t.Errorf("Expected the resolved chain next to the group, got:\n%s", view)
+	}
+	if !strings.Contains(view, "Auto-HK ▸") {
+		t.Errorf("Expected the nested group to be marked, got:\n%s", view)
+	}
+	if !strings.Contains(view, "↻ cycle") {
+		t.Errorf("Expected the cycle between Loop-A and Loop-B to be flagged, got:\n%s", view)
+	}
+
+	// → on the nested group opens it, with the way back shown
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m = newModel.(Model)
+	if group, proxy, _ := m.cursorProxy(); group != "Auto-HK" || proxy != "HK-03" {
+		t.Fatalf("Expected to drill into Auto-HK on its active proxy, got %s/%s", group, proxy)
+	}
+	if !strings.Contains(m.View(), "from Proxy") {
+		t.Errorf("Expected a breadcrumb, got:\n%s", m.View())
+	}
+
+	// ← goes back onto the member the group was opened through
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	m = newModel.(Model)
+	if group, proxy, _ := m.cursorProxy(); group != "Proxy" || proxy != "Auto-HK" {
+		t.Errorf("Expected to be back on Proxy/Auto-HK, got %s/%s", group, proxy)
+	}
+	if len(m.trail()) != 0 {
+		t.Errorf("Expected the trail to be empty at the top, got %+v", m.trail())
+	}
+}
+
+func TestGroupOrder(t *testing.T) {
+	proxies := map[string]clash.Proxy{
+		"GLOBAL": {Name: "GLOBAL", Type: "Selector", Now: "Zeta", All: []string{"DIRECT", "Zeta", "Alpha", "Mid"}},
+		"Zeta":   {Name: "Zeta", Type: "Selector", Now: "DIRECT", All: []string{"DIRECT"}},
+		"Alpha":  {Name: "Alpha", Type: "Selector", Now: "DIRECT", All: []string{"DIRECT"}},
+		"Mid":    {Name: "Mid", Type: "Selector", Now: "DIRECT", All: []string{"DIRECT"}},
+		"Orphan": {Name: "Orphan", Type: "Selector", Now: "DIRECT", All: []string{"DIRECT"}},
+	}
+	m := NewModel(config.Default(), clash.NewMock(proxies))
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	// Config order from GLOBAL, unlisted groups (GLOBAL among them) after it
+	want := []string{"Zeta", "Alpha", "Mid", "GLOBAL", "Orphan"}
+	if strings.Join(m.Groups, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected groups in config order %q, got %q", want, m.Groups)
+	}
+
+	// A group going away leaves the others where they were
+	m.jumpTo("Mid", "")
+	delete(proxies, "Alpha")
+	global := proxies["GLOBAL"]
+	global.All = []string{"DIRECT", "Zeta", "Mid"}
+	proxies["GLOBAL"] = global
+	newModel, _ = m.Update(newProxiesLoadedMsg(proxies))
+	m = newModel.(Model)
+	want = []string{"Zeta", "Mid", "GLOBAL", "Orphan"}
+	if strings.Join(m.Groups, ",") != strings.Join(want, ",") || m.Groups[m.CurrentIdx] != "Mid" {
+		t.Errorf("Expected %q with Mid still selected, got %q at %d", want, m.Groups, m.CurrentIdx)
+	}
+
+	cfg := config.Default()
+	cfg.UI.GroupOrder = "name"
+	m = NewModel(cfg, clash.NewMock(proxies))
+	newModel, _ = m.Update(m.Init()())
+	m = newModel.(Model)
+	want = []string{"GLOBAL", "Mid", "Orphan", "Zeta"}
+	if strings.Join(m.Groups, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected groups by name %q, got %q", want, m.Groups)
+	}
+}
+
+func TestProxyMetadata(t *testing.T) {
+	dead, alive := false, true
+	tested := []clash.ProxyHistory{{Time: time.Now().Add(-time.Second).Format(time.RFC3339Nano), Delay: 120}}
+	backend := clash.NewMock(map[string]clash.Proxy{
+		"Proxy":  {Name: "Proxy", Type: "Selector", Now: "HK-01", All: []string{"HK-01", "JP-01"}},
+		"Inner":  {Name: "Inner", Type: "URLTest", Now: "JP-01", All: []string{"JP-01"}, Hidden: true},
+		"HK-01":  {Name: "HK-01", Type: "Vmess", UDP: true, Alive: &alive, History: tested},
+		"JP-01":  {Name: "JP-01", Type: "Trojan", Alive: &dead},
+		"Unused": {Name: "Unused", Type: "Direct"},
+	})
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	if len(m.Groups) != 1 || m.Groups[0] != "Proxy" {
+		t.Errorf("Expected the hidden group to be left out, got %q", m.Groups)
+	}
+
+	view := m.View()
+	if !strings.Contains(view, "HK-01 udp") {
+		t.Errorf("Expected HK-01 to show UDP support, got:\n%s", view)
+	}
+	if !strings.Contains(view, "JP-01 ✗") {
+		t.Errorf("Expected JP-01 to be marked dead, got:\n%s", view)
+	}
+
+	// The newest test decides, from this session or from the controller
+	m.Delays = map[string]clash.DelayResult{
+		"HK-01": {Proxy: "HK-01", Status: clash.DelayTimeout, Time: time.Now().Add(-time.Minute)},
+	}
+	if view := m.View(); strings.Contains(view, "HK-01 ✗") || !strings.Contains(view, "120ms") {
+		t.Errorf("Expected the newer history to keep HK-01 alive, got:\n%s", view)
+	}
+	m.Delays["HK-01"] = clash.DelayResult{Proxy: "HK-01", Status: clash.DelayTimeout, Time: time.Now()}
+	if view := m.View(); !strings.Contains(view, "HK-01 ✗") || !strings.Contains(view, "timeout") {
+		t.Errorf("Expected the newer session test to mark HK-01 dead, got:\n%s", view)
+	}
+}
+
+func TestDetailPanel(t *testing.T) {
+	m := NewModel(config.Default(), clash.NewMock(nil))
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.WindowSizeMsg{Width: 120, Height: 30})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'i'}})
+	m = newModel.(Model)
+
+	// Wide terminal: list and panel side by side
+	view := m.View()
+	for _, want := range []string{"Proxy Group A (Selector)", "Provider  sample-subscription", "In groups Proxy Group A", "History"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the view, got:\n%s", want, view)
+		}
+	}
+	for _, line := range strings.Split(view, "\n") {
+		if w := lipgloss.Width(line); w > 120 {
+			t.Errorf("Expected lines to fit the terminal, got %d cells: %q", w, line)
+		}
+	}
+
+	// Narrow terminal: the panel replaces the list until closed
+	newModel, _ = m.Update(tea.WindowSizeMsg{Width: 60, Height: 30})
+	m = newModel.(Model)
+	if view := m.View(); strings.Contains(view, "Proxy Group B") || !strings.Contains(view, "[i/Esc]Close") {
+		t.Errorf("Expected the panel as a modal, got:\n%s", view)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = newModel.(Model)
+	if m.detail || !strings.Contains(m.View(), "Proxy Group B") {
+		t.Errorf("Expected Esc to close the modal, got:\n%s", m.View())
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	cfg := config.Default()
+	cfg.Profiles = []config.Profile{
+		{Name: "workstation", URL: "http://127.0.0.1:9090"},
+		{Name: "router", URL: "http://192.168.1.1:9090"},
+	}
+	m := NewModel(cfg, clash.NewMock(nil))
+	m.profiles.clients = map[string]clash.Backend{
+		"workstation": m.Client,
+		"router": clash.NewMock(map[string]clash.Proxy{
+			"Home": {Name: "Home", Type: "Selector", Now: "DIRECT", All: []string{"DIRECT"}},
+		}),
+	}
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	m.jumpTo("Proxy Group B", "Auto-4")
+	if !strings.Contains(strings.Split(m.View(), "\n")[0], "Controller workstation") {
+		t.Errorf("Expected the active profile in the header, got:\n%s", m.View())
+	}
+
+	pick := func(key tea.KeyType) tea.Cmd {
+		t.Helper()
+		newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'P'}})
+		m = newModel.(Model)
+		if !m.profiles.picking {
+			t.Fatalf("Expected P to open the picker")
+		}
+		newModel, _ = m.Update(tea.KeyMsg{Type: key})
+		m = newModel.(Model)
+		newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m = newModel.(Model)
+		return cmd
+	}
+
+	// Switching cancels what is in flight and loads the other controller
+	old := m.ctx
+	cmd := pick(tea.KeyDown)
+	if old.Err() == nil {
+		t.Errorf("Expected the requests of the previous profile to be cancelled")
+	}
+	if m.profileName() != "router" || !strings.Contains(m.View(), "Controller router") {
+		t.Fatalf("Expected router to be active, got %q", m.profileName())
+	}
+	newModel, _ = m.Update(waitMsg[proxiesLoadedMsg](t, cmd))
+	m = newModel.(Model)
+	if len(m.Groups) != 1 || m.Groups[0] != "Home" {
+		t.Errorf("Expected the router's groups, got %q", m.Groups)
+	}
+
+	// Back on the workstation, its group and cursor are as they were left
+	cmd = pick(tea.KeyUp)
+	newModel, _ = m.Update(waitMsg[proxiesLoadedMsg](t, cmd))
+	m = newModel.(Model)
+	if group, proxy, _ := m.cursorProxy(); group != "Proxy Group B" || proxy != "Auto-4" {
+		t.Errorf("Expected Proxy Group B/Auto-4 to be restored, got %s/%s", group, proxy)
+	}
+}
+
+func TestModeSwitch(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+	// GLOBAL lists every other group, so it comes last in config order
+	if m.Groups[len(m.Groups)-1] != "GLOBAL" {
+		t.Errorf("Expected GLOBAL in config order outside global mode, got %q", m.Groups)
+	}
+
+	cfg, _ := backend.GetConfig(context.Background())
+	newModel, _ = m.Update(runtimeConfigLoadedMsg{cfg: cfg})
+	m = newModel.(Model)
+	if !strings.Contains(strings.Split(m.View(), "\n")[0], "Mode rule") {
+		t.Errorf("Expected the mode in the header, got:\n%s", m.View())
+	}
+
+	// rule -> global: GLOBAL moves to the top, the current group stays selected
+	current := m.Groups[m.CurrentIdx]
+	newModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}})
+	m = newModel.(Model)
+	patched := waitMsg[configPatchedMsg](t, cmd)
+	newModel, _ = m.Update(patched)
+	m = newModel.(Model)
+	if m.mode() != "global" || m.Groups[0] != "GLOBAL" || m.Groups[m.CurrentIdx] != current {
+		t.Errorf("Expected GLOBAL on top in global mode with %q still selected, got %q", current, m.Groups)
+	}
+	if m.notice.err {
+		t.Errorf("Expected the change to be verified, got %q", m.notice.text)
+	}
+
+	// A change the controller ignored is reported
+	newModel, _ = m.Update(configPatchedMsg{change: patched.change, cfg: &clash.RuntimeConfig{Mode: "rule"}})
+	if n := newModel.(Model).notice; !n.err || !strings.Contains(n.text, "did not apply") {
+		t.Errorf("Expected an error notice for an unapplied change, got %q", n.text)
+	}
+}
+
+// waitMsg runs cmd, and each command of a batch concurrently so timers do
+// not hold it up, and returns the first message of type T.
+func waitMsg[T tea.Msg](t *testing.T, cmd tea.Cmd) T {
+	t.Helper()
+	msg := cmd()
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		found, ok := msg.(T)
+		if !ok {
+			t.Fatalf("Expected a %T message, got %T", found, msg)
+		}
+		return found
+	}
+	msgs := make(chan tea.Msg, len(batch))
+	for _, c := range batch {
+		go func() { msgs <- c() }()
+	}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case msg := <-msgs:
+			if found, ok := msg.(T); ok {
+				return found
+			}
+		case <-timeout:
+			var zero T
+			t.Fatalf("Expected a %T message", zero)
+			return zero
+		}
+	}
+}
+
+func TestConfigScreen(t *testing.T) {
+	backend := clash.NewMock(nil)
+	m := NewModel(config.Default(), backend)
+	m.Height = 12
+	newModel, _ := m.Update(m.Init()())
+	m = newModel.(Model)
+
+	var cmd tea.Cmd
+	for m.screen != screenConfig {
+		newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = newModel.(Model)
+	}
+	newModel, _ = m.Update(cmd())
+	m = newModel.(Model)
+	if view := m.View(); !strings.Contains(view, "7890") || len(strings.Split(view, "\n")) != m.Height {
+		t.Fatalf("Expected the ports within %d lines, got:\n%s", m.Height, view)
+	}
+
+	// Read-only fields cannot be changed
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	if m.configScreen.editing {
+		t.Errorf("Expected the mixed port to be read-only")
+	}
+
+	move := func(label string) {
+		for configFields[m.configScreen.cursor].label != label {
+			newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+			m = newModel.(Model)
+		}
+	}
+	apply := func(keys ...tea.KeyMsg) {
+		t.Helper()
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m = newModel.(Model)
+		for _, k := range keys {
+			newModel, _ = m.Update(k)
+			m = newModel.(Model)
+		}
+		if !m.configScreen.editing || !strings.Contains(m.View(), "[y]Apply") {
+			t.Fatalf("Expected a confirmation prompt, got:\n%s", m.View())
+		}
+		newModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+		m = newModel.(Model)
+		newModel, _ = m.Update(waitMsg[configPatchedMsg](t, cmd))
+		m = newModel.(Model)
+		if m.notice.err {
+			t.Errorf("Expected the change to be verified, got %q", m.notice.text)
+		}
+	}
+
+	move("Allow LAN")
+	apply()
+	move("Log level")
+	apply(tea.KeyMsg{Type: tea.KeyRight}) // info -> warning -> error
+	move("TUN")
+	apply()
+
+	cfg, _ := backend.GetConfig(context.Background())
+	if !cfg.AllowLan || cfg.LogLevel != "error" || !cfg.Tun.Enable {
+		t.Errorf("Expected allow-lan, log-level and tun to be changed, got %+v", cfg)
+	}
+	if !m.runtime.Tun.Enable {
+		t.Errorf("Expected the configuration to be read back after a change")
+	}
+
+	// Declining leaves the configuration alone, and only y applies
+	for _, key := range []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune{'n'}}, {Type: tea.KeyEnter}} {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		m = newModel.(Model)
+		newModel, cmd = m.Update(key)
+		m = newModel.(Model)
+		if m.configScreen.editing || cmd != nil {
+			t.Errorf("Expected %s to cancel the change", key)
+		}
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	c := listCursor{}
+	for _, step := range []struct {
+		cursor, total, rows    int
+		wantCursor, wantOffset int
+	}{
+		{5, 10, 3, 5, 3},  // Scrolls down to keep the cursor on the last row
+		{1, 10, 3, 1, 1},  // Scrolls up to keep it on the first
+		{20, 10, 3, 9, 7}, // Clamps to the last item
+		{9, 4, 3, 3, 1},   // Follows a shrinking list
+		{-1, 0, 3, 0, 0},  // Stays at the top of an empty one
+	} {
+		c.cursor = step.cursor
+		c.sync(step.total, step.rows)
+		if c.cursor != step.wantCursor || c.offset != step.wantOffset {
+			t.Errorf("sync(%d, %d) from cursor %d: got cursor %d offset %d, want %d and %d",
+				step.total, step.rows, step.cursor, c.cursor, c.offset, step.wantCursor, step.wantOffset)
+		}
+	}
+}
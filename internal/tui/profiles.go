@@ -0,0 +1,184 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/config"
+)
+
+// profileState is the part of the UI remembered per profile while another
+// one is shown.
+type profileState struct {
+	group string
+	proxy string
+}
+
+// profileSet holds the controllers to switch between. Clients are built
+// when a profile is first used and kept, like the UI state of the profiles
+// left behind.
+type profileSet struct {
+	list    []config.Profile
+	active  int
+	connect func(config.Profile) clash.Backend
+	clients map[string]clash.Backend
+	saved   map[string]profileState
+	picking bool // Whether the picker is open
+	cursor  int
+}
+
+// connectProfile builds the backend of a profile: the mock backend in mock
+// mode, a controller client otherwise.
+func connectProfile(cfg config.Config, p config.Profile) clash.Backend {
+	if cfg.Mock {
+		return clash.NewMock(nil)
+	}
+	return clash.NewClient(p.URL, p.Secret, clash.Timeouts{
+		Connect: cfg.Controller.ConnectTimeout,
+		Read:    cfg.Controller.ReadTimeout,
+	})
+}
+
+func newProfileSet(cfg config.Config, client clash.Backend) profileSet {
+	list := cfg.ProfileList()
+	active := cfg.ActiveProfile()
+	return profileSet{
+		list:    list,
+		active:  active,
+		connect: func(p config.Profile) clash.Backend { return connectProfile(cfg, p) },
+		clients: map[string]clash.Backend{list[active].Name: client},
+	}
+}
+
+// profileName returns the name of the active profile.
+func (m Model) profileName() string {
+	if m.profiles.active >= len(m.profiles.list) {
+		return ""
+	}
+	return m.profiles.list[m.profiles.active].Name
+}
+
+// switchProfile moves to the i-th profile. Everything in flight for the
+// current one is cancelled and the model starts over on the other
+// controller, keeping the size of the terminal and, once the proxies are
+// loaded, the group and proxy last shown there.
+func (m Model) switchProfile(i int) (tea.Model, tea.Cmd) {
+	set := m.profiles
+	set.picking = false
+	if i == set.active {
+		m.profiles = set
+		return m, nil
+	}
+
+	saved := make(map[string]profileState, len(set.saved)+1)
+	for k, v := range set.saved {
+		saved[k] = v
+	}
+	if m.CurrentIdx < len(m.Groups) {
+		saved[m.profileName()] = profileState{group: m.Groups[m.CurrentIdx], proxy: m.lastCursorProxy}
+	}
+	target := set.list[i]
+	clients := make(map[string]clash.Backend, len(set.clients)+1)
+	for k, v := range set.clients {
+		clients[k] = v
+	}
+	client, ok := clients[target.Name]
+	if !ok {
+		client = set.connect(target)
+		clients[target.Name] = client
+	}
+
+	if m.cancel != nil {
+		m.cancel()
+	}
+	cfg := m.Config
+	cfg.Controller.URL, cfg.Controller.Secret = target.URL, target.Secret
+	next := NewModel(cfg, client)
+	next.Height, next.Width = m.Height, m.Width
+	next.notice.id = m.notice.id // A pending clear of the old notice must not clear the new one
+	set.active, set.saved, set.clients = i, saved, clients
+	next.profiles = set
+	if state, ok := saved[target.Name]; ok {
+		next.restore = &state
+	}
+	notice := next.setNotice("Switched to "+target.Name, false)
+	return next, tea.Batch(next.Init(), notice)
+}
+
+// restoreProfileState brings back the group and proxy last shown for the
+// profile, once its proxies are loaded.
+func (m *Model) restoreProfileState() {
+	if m.restore == nil {
+		return
+	}
+	state := *m.restore
+	m.restore = nil
+	m.jumpTo(state.group, state.proxy)
+}
+
+func (m *Model) openProfilePicker() {
+	m.profiles.picking = true
+	m.profiles.cursor = m.profiles.active
+}
+
+func (m Model) updateProfilePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "P", "q":
+		m.profiles.picking = false
+	case "ctrl+c":
+		return m.quit()
+	case "up", "k", "ctrl+k":
+		m.profiles.cursor = max(m.profiles.cursor-1, 0)
+	case "down", "j", "ctrl+j":
+		m.profiles.cursor = min(m.profiles.cursor+1, len(m.profiles.list)-1)
+	case "enter":
+		return m.switchProfile(m.profiles.cursor)
+	}
+	return m, nil
+}
+
+const profileNameWidth = 16
+
+// profilePickerView renders the picker in place of the group list.
+func (m Model) profilePickerView() string {
+	lines := []string{headerStyle.Render("Controllers")}
+	for i, p := range m.profiles.list {
+		row := fit(p.Name, profileNameWidth) + " " + helpStyle.Render(p.URL)
+		if i == m.profiles.active {
+			row += activeProxyMarkStyle.Render(" ●")
+		}
+		if i == m.profiles.cursor {
+			lines = append(lines, cursorStyle.Render("> ")+row)
+		} else {
+			lines = append(lines, "  "+row)
+		}
+	}
+
+	box := finderBoxStyle.Render(strings.Join(lines, "\n"))
+	if padding := m.bodyHeight() - lipgloss.Height(box) - minHelpRows; padding > 0 {
+		box += strings.Repeat("\n", padding)
+	}
+	return box + "\n" + helpStyle.Render(" [↑↓]Move  [Ent]Switch  [Esc]Close")
+}
+
+// profileLabel renders the active profile for the header, when there is a
+// choice of profiles at all.
+func (m Model) profileLabel() string {
+	if len(m.profiles.list) < 2 {
+		return ""
+	}
+	return helpStyle.Render(" Controller ") + headerStyle.Render(m.profileName()) +
+		helpStyle.Render(fmt.Sprintf(" (%d/%d)", m.profiles.active+1, len(m.profiles.list)))
+}
+
+// profileHint offers the picker on the error screen, as another controller
+// may well be reachable.
+func (m Model) profileHint() string {
+	if len(m.profiles.list) < 2 {
+		return ""
+	}
+	return "[P] other controller, "
+}
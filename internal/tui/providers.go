@@ -0,0 +1,254 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+// providersView is the state of the proxy providers screen.
+type providersView struct {
+	list   []clash.ProxyProvider
+	loaded bool
+	err    error
+	listCursor
+	busy map[string]string // Action in flight per provider, e.g. "updating"
+}
+
+type providersLoadedMsg struct {
+	resp *clash.ProxyProvidersResponse
+	err  error
+}
+
+type providerActionMsg struct {
+	name   string
+	action string // "update" or "health check"
+	err    error
+}
+
+func loadProvidersCmd(ctx context.Context, client clash.Backend) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.GetProxyProviders(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return providersLoadedMsg{resp: resp, err: err}
+	}
+}
+
+func updateProviderCmd(ctx context.Context, client clash.Backend, name string) tea.Cmd {
+	return func() tea.Msg {
+		err := client.UpdateProxyProvider(ctx, name)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return providerActionMsg{name: name, action: "update", err: err}
+	}
+}
+
+func healthCheckProviderCmd(ctx context.Context, client clash.Backend, name string) tea.Cmd {
+	return func() tea.Msg {
+		err := client.HealthCheckProxyProvider(ctx, name)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return providerActionMsg{name: name, action: "health check", err: err}
+	}
+}
+
+// openProviders reloads the providers every time their screen is shown.
+func (m *Model) openProviders() tea.Cmd {
+	return loadProvidersCmd(m.context(), m.Client)
+}
+
+// sortedProviders drops the providers the controller makes up for the
+// proxies defined inline, and orders the rest by name.
+func sortedProviders(providers map[string]clash.ProxyProvider) []clash.ProxyProvider {
+	list := make([]clash.ProxyProvider, 0, len(providers))
+	for name, p := range providers {
+		if p.VehicleType == "Compatible" {
+			continue
+		}
+		p.Name = name
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return naturalLess(list[i].Name, list[j].Name) })
+	return list
+}
+
+func (m *Model) syncProviderCursor() {
+	m.providers.sync(len(m.providers.list), m.listRows())
+}
+
+func (m *Model) moveProviderCursor(delta int) {
+	m.providers.cursor += delta
+	m.syncProviderCursor()
+}
+
+// startProviderAction marks the provider under the cursor busy and returns
+// the command doing the action, unless another one is still running.
+func (m *Model) startProviderAction(label string, cmd func(context.Context, clash.Backend, string) tea.Cmd) tea.Cmd {
+	if m.providers.cursor >= len(m.providers.list) {
+		return nil
+	}
+	name := m.providers.list[m.providers.cursor].Name
+	if _, ok := m.providers.busy[name]; ok {
+		return nil
+	}
+	busy := make(map[string]string, len(m.providers.busy)+1)
+	for k, v := range m.providers.busy {
+		busy[k] = v
+	}
+	busy[name] = label
+	m.providers.busy = busy
+	return cmd(m.context(), m.Client, name)
+}
+
+func (m Model) updateProvidersMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case providersLoadedMsg:
+		m.providers.loaded = true
+		m.providers.err = msg.err
+		if msg.err == nil {
+			m.providers.list = sortedProviders(msg.resp.Providers)
+		}
+		m.syncProviderCursor()
+		return m, nil
+
+	case providerActionMsg:
+		busy := make(map[string]string, len(m.providers.busy))
+		for k, v := range m.providers.busy {
+			if k != msg.name {
+				busy[k] = v
+			}
+		}
+		m.providers.busy = busy
+		if msg.err != nil {
+			return m, tea.Batch(m.setNotice(fmt.Sprintf("Failed to %s %s: %v", msg.action, msg.name, msg.err), true),
+				loadProvidersCmd(m.context(), m.Client))
+		}
+		// The provider's nodes (or their latency) changed, so refresh the
+		// groups too
+		done := "Updated " + msg.name
+		if msg.action == "health check" {
+			done = "Health-checked " + msg.name
+		}
+		return m, tea.Batch(m.setNotice(done, false),
+			loadProvidersCmd(m.context(), m.Client), LoadProxiesCmd(m.newLoadContext(), m.Client))
+	}
+	return m, nil
+}
+
+func (m Model) updateProvidersKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if next, cmd, ok := m.updateListKey(msg, (*Model).moveProviderCursor); ok {
+		return next, cmd
+	}
+	switch msg.String() {
+	case "u":
+		return m, m.startProviderAction("updating", updateProviderCmd)
+	case "h":
+		return m, m.startProviderAction("checking", healthCheckProviderCmd)
+	case "r":
+		return m, loadProvidersCmd(m.context(), m.Client)
+	}
+	return m, nil
+}
+
+// Column widths of the providers table
+const (
+	providerNameWidth    = 24
+	providerVehicleWidth = 7
+	providerNodesWidth   = 5
+	providerHealthWidth  = 12
+	providerAgeWidth     = 7
+)
+
+func (m Model) providersView() string {
+	title := headerStyle.Render(fmt.Sprintf(" Proxy providers %d", len(m.providers.list)))
+	header := "   " + fit("Name", providerNameWidth) + " " + fit("Vehicle", providerVehicleWidth) + " " +
+		fmt.Sprintf("%*s", providerNodesWidth, "Nodes") + " " + fit("Health", providerHealthWidth) + " " +
+		fmt.Sprintf("%*s", providerAgeWidth, "Updated") + "  Subscription"
+	lines := []string{title, separatorStyle.Render(header)}
+
+	rows := m.listRows()
+	switch {
+	case m.providers.err != nil:
+		lines = append(lines, errorStyle.Render(fmt.Sprintf("   %v", m.providers.err)))
+	case !m.providers.loaded:
+		lines = append(lines, helpStyle.Render("   Loading providers..."))
+	case len(m.providers.list) == 0:
+		lines = append(lines, helpStyle.Render("   No proxy providers"))
+	}
+	now := time.Now()
+	for i := m.providers.offset; i < len(m.providers.list) && i < m.providers.offset+rows && m.providers.err == nil; i++ {
+		p := m.providers.list[i]
+		row := fit(p.Name, providerNameWidth) + " " + fit(p.VehicleType, providerVehicleWidth) + " " +
+			fmt.Sprintf("%*d", providerNodesWidth, len(p.Proxies)) + " " +
+			providerHealth(p) + " " +
+			fmt.Sprintf("%*s", providerAgeWidth, formatAge(now.Sub(p.UpdatedAt), p.UpdatedAt.IsZero())) + "  " +
+			subscriptionText(p.SubscriptionInfo, now)
+		if busy, ok := m.providers.busy[p.Name]; ok {
+			row += " " + pendingMarkStyle.Render(busy+"...")
+		}
+		if i == m.providers.cursor {
+			lines = append(lines, cursorStyle.Render(">  ")+row)
+		} else {
+			lines = append(lines, "   "+row)
+		}
+	}
+
+	for len(lines) < m.bodyHeight()-minHelpRows {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n" + m.providersHelp()
+}
+
+// providerHealth renders how many of the provider's tested nodes are alive,
+// padded to its column.
+func providerHealth(p clash.ProxyProvider) string {
+	alive, tested := p.Alive()
+	if tested == 0 {
+		return latencyErrorStyle.Render(fit("untested", providerHealthWidth))
+	}
+	text := fit(fmt.Sprintf("%d/%d alive", alive, tested), providerHealthWidth)
+	switch {
+	case alive == tested:
+		return latencyGoodStyle.Render(text)
+	case alive > 0:
+		return latencyFairStyle.Render(text)
+	default:
+		return latencySlowStyle.Render(text)
+	}
+}
+
+// subscriptionText summarises the traffic used and the expiry of a
+// subscription.
+func subscriptionText(info *clash.SubscriptionInfo, now time.Time) string {
+	if info == nil {
+		return ""
+	}
+	var parts []string
+	if info.Total > 0 {
+		parts = append(parts, fmt.Sprintf("%s / %s", formatBytes(info.Upload+info.Download), formatBytes(info.Total)))
+	}
+	if info.Expire > 0 {
+		if left := time.Unix(info.Expire, 0).Sub(now); left > 0 {
+			parts = append(parts, formatAge(left, false)+" left")
+		} else {
+			parts = append(parts, "expired")
+		}
+	}
+	return helpStyle.Render(strings.Join(parts, ", "))
+}
+
+func (m Model) providersHelp() string {
+	if m.notice.text != "" {
+		return m.noticeLine()
+	}
+	return helpStyle.Render(" [↑k]↑ [↓j]↓  [u]Update [h]Health check  [r]Reload  [Tab]Screens  [q]Quit")
+}
@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+// ruleProvidersView is the state of the rule providers screen.
+type ruleProvidersView struct {
+	list   []clash.RuleProvider
+	loaded bool
+	err    error
+	listCursor
+	busy      map[string]bool // Providers being refreshed
+	refreshed int             // Refreshes succeeded since none was running
+	failed    int             // Refreshes failed since none was running
+}
+
+type ruleProvidersLoadedMsg struct {
+	resp *clash.RuleProvidersResponse
+	err  error
+}
+
+type ruleProviderRefreshedMsg struct {
+	name string
+	err  error
+}
+
+func loadRuleProvidersCmd(ctx context.Context, client clash.Backend) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.GetRuleProviders(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return ruleProvidersLoadedMsg{resp: resp, err: err}
+	}
+}
+
+func refreshRuleProviderCmd(ctx context.Context, client clash.Backend, name string) tea.Cmd {
+	return func() tea.Msg {
+		err := client.UpdateRuleProvider(ctx, name)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return ruleProviderRefreshedMsg{name: name, err: err}
+	}
+}
+
+// openRuleProviders reloads the rule providers every time their screen is
+// shown.
+func (m *Model) openRuleProviders() tea.Cmd {
+	return loadRuleProvidersCmd(m.context(), m.Client)
+}
+
+func (m *Model) syncRuleProviderCursor() {
+	m.ruleProviders.sync(len(m.ruleProviders.list), m.listRows())
+}
+
+func (m *Model) moveRuleProviderCursor(delta int) {
+	m.ruleProviders.cursor += delta
+	m.syncRuleProviderCursor()
+}
+
+// refreshRuleProviders refreshes the named providers that are not being
+// refreshed already.
+func (m *Model) refreshRuleProviders(names ...string) tea.Cmd {
+	busy := make(map[string]bool, len(m.ruleProviders.busy)+len(names))
+	for k := range m.ruleProviders.busy {
+		busy[k] = true
+	}
+	var cmds []tea.Cmd
+	for _, name := range names {
+		if busy[name] {
+			continue
+		}
+		busy[name] = true
+		cmds = append(cmds, refreshRuleProviderCmd(m.context(), m.Client, name))
+	}
+	m.ruleProviders.busy = busy
+	return tea.Batch(cmds...)
+}
+
+func (m Model) updateRuleProvidersMsg(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ruleProvidersLoadedMsg:
+		m.ruleProviders.loaded = true
+		m.ruleProviders.err = msg.err
+		if msg.err == nil {
+			list := make([]clash.RuleProvider, 0, len(msg.resp.Providers))
+			for name, p := range msg.resp.Providers {
+				p.Name = name
+				list = append(list, p)
+			}
+			sort.Slice(list, func(i, j int) bool { return naturalLess(list[i].Name, list[j].Name) })
+			m.ruleProviders.list = list
+		}
+		m.syncRuleProviderCursor()
+		return m, nil
+
+	case ruleProviderRefreshedMsg:
+		busy := make(map[string]bool, len(m.ruleProviders.busy))
+		for k := range m.ruleProviders.busy {
+			if k != msg.name {
+				busy[k] = true
+			}
+		}
+		m.ruleProviders.busy = busy
+		reload := loadRuleProvidersCmd(m.context(), m.Client)
+		if msg.err != nil {
+			m.ruleProviders.failed++
+		} else {
+			m.ruleProviders.refreshed++
+		}
+		if len(busy) > 0 {
+			if msg.err != nil {
+				return m, tea.Batch(m.setNotice(fmt.Sprintf("Failed to refresh %s: %v", msg.name, msg.err), true), reload)
+			}
+			return m, reload
+		}
+		// Sum up once every refresh started together has finished
+		done, failed := m.ruleProviders.refreshed, m.ruleProviders.failed
+		m.ruleProviders.refreshed, m.ruleProviders.failed = 0, 0
+		switch {
+		case done+failed == 1 && msg.err != nil:
+			return m, tea.Batch(m.setNotice(fmt.Sprintf("Failed to refresh %s: %v", msg.name, msg.err), true), reload)
+		case done == 1 && failed == 0:
+			return m, tea.Batch(m.setNotice("Refreshed "+msg.name, false), reload)
+		case failed > 0:
+			return m, tea.Batch(m.setNotice(fmt.Sprintf("Refreshed %d, %d failed", done, failed), true), reload)
+		}
+		return m, tea.Batch(m.setNotice(fmt.Sprintf("Refreshed %d rule providers", done), false), reload)
+	}
+	return m, nil
+}
+
+func (m Model) updateRuleProvidersKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if next, cmd, ok := m.updateListKey(msg, (*Model).moveRuleProviderCursor); ok {
+		return next, cmd
+	}
+	switch msg.String() {
+	case "u":
+		if m.ruleProviders.cursor < len(m.ruleProviders.list) {
+			return m, m.refreshRuleProviders(m.ruleProviders.list[m.ruleProviders.cursor].Name)
+		}
+	case "U":
+		names := make([]string, len(m.ruleProviders.list))
+		for i, p := range m.ruleProviders.list {
+			names[i] = p.Name
+		}
+		return m, m.refreshRuleProviders(names...)
+	case "r":
+		return m, loadRuleProvidersCmd(m.context(), m.Client)
+	}
+	return m, nil
+}
+
+// Column widths of the rule providers table
+const (
+	ruleProviderNameWidth     = 24
+	ruleProviderVehicleWidth  = 7
+	ruleProviderBehaviorWidth = 9
+	ruleProviderFormatWidth   = 9
+	ruleProviderCountWidth    = 7
+	ruleProviderAgeWidth      = 7
+)
+
+func (m Model) ruleProvidersView() string {
+	title := headerStyle.Render(fmt.Sprintf(" Rule providers %d", len(m.ruleProviders.list)))
+	header := "   " + fit("Name", ruleProviderNameWidth) + " " + fit("Vehicle", ruleProviderVehicleWidth) + " " +
+		fit("Behavior", ruleProviderBehaviorWidth) + " " + fit("Format", ruleProviderFormatWidth) + " " +
+		fmt.Sprintf("%*s %*s", ruleProviderCountWidth, "Rules", ruleProviderAgeWidth, "Updated")
+	lines := []string{title, separatorStyle.Render(header)}
+
+	rows := m.listRows()
+	switch {
+	case m.ruleProviders.err != nil:
+		lines = append(lines, errorStyle.Render(fmt.Sprintf("   %v", m.ruleProviders.err)))
+	case !m.ruleProviders.loaded:
+		lines = append(lines, helpStyle.Render("   Loading rule providers..."))
+	case len(m.ruleProviders.list) == 0:
+		lines = append(lines, helpStyle.Render("   No rule providers"))
+	}
+	now := time.Now()
+	for i := m.ruleProviders.offset; i < len(m.ruleProviders.list) && i < m.ruleProviders.offset+rows && m.ruleProviders.err == nil; i++ {
+		p := m.ruleProviders.list[i]
+		row := fit(p.Name, ruleProviderNameWidth) + " " + fit(p.VehicleType, ruleProviderVehicleWidth) + " " +
+			fit(p.Behavior, ruleProviderBehaviorWidth) + " " + fit(p.Format, ruleProviderFormatWidth) + " " +
+			fmt.Sprintf("%*d %*s", ruleProviderCountWidth, p.RuleCount,
+				ruleProviderAgeWidth, formatAge(now.Sub(p.UpdatedAt), p.UpdatedAt.IsZero()))
+		if m.ruleProviders.busy[p.Name] {
+			row += " " + pendingMarkStyle.Render("refreshing...")
+		}
+		if i == m.ruleProviders.cursor {
+			lines = append(lines, cursorStyle.Render(">  ")+row)
+		} else {
+			lines = append(lines, "   "+normalStyle.Render(row))
+		}
+	}
+
+	for len(lines) < m.bodyHeight()-minHelpRows {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n" + m.ruleProvidersHelp()
+}
+
+func (m Model) ruleProvidersHelp() string {
+	if m.notice.text != "" {
+		return m.noticeLine()
+	}
+	return helpStyle.Render(" [↑k]↑ [↓j]↓  [u]Refresh [U]Refresh all  [r]Reload  [Tab]Screens  [q]Quit")
+}
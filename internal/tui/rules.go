@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+// rulesView is the state of the rules screen.
+type rulesView struct {
+	list   []clash.Rule
+	loaded bool
+	err    error
+	listCursor
+	filter    textInput
+	filtering bool
+}
+
+type rulesLoadedMsg struct {
+	resp *clash.RulesResponse
+	err  error
+}
+
+func loadRulesCmd(ctx context.Context, client clash.Backend) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.GetRules(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return rulesLoadedMsg{resp: resp, err: err}
+	}
+}
+
+// openRules reloads the rules every time their screen is shown, they change
+// with the controller's config.
+func (m *Model) openRules() tea.Cmd {
+	return loadRulesCmd(m.context(), m.Client)
+}
+
+// ruleIndex pairs a rule with its position in the controller's list, which
+// is the order rules are matched in.
+type ruleIndex struct {
+	index int
+	rule  clash.Rule
+}
+
+// visibleRules returns the rules matching the filter by type, payload or
+// target.
+func (m Model) visibleRules() []ruleIndex {
+	list := make([]ruleIndex, 0, len(m.rules.list))
+	for i, r := range m.rules.list {
+		if matchesFilter(r.Type+" "+r.Payload+" "+r.Proxy, m.rules.filter.value) {
+			list = append(list, ruleIndex{index: i, rule: r})
+		}
+	}
+	return list
+}
+
+// syncRuleCursor keeps the cursor on the visible rules and the viewport
+// around the cursor.
+func (m *Model) syncRuleCursor() {
+	m.rules.sync(len(m.visibleRules()), m.listRows())
+}
+
+func (m *Model) moveRuleCursor(delta int) {
+	m.rules.cursor += delta
+	m.syncRuleCursor()
+}
+
+// jumpToRuleTarget shows the rule's target in the proxies screen: the group
+// itself, or the first group that has the target as a member.
+func (m *Model) jumpToRuleTarget() tea.Cmd {
+	list := m.visibleRules()
+	if m.rules.cursor >= len(list) {
+		return nil
+	}
+	target := list[m.rules.cursor].rule.Proxy
+	for _, g := range m.Groups {
+		if g == target {
+			m.screen = screenProxies
+			m.jumpTo(g, "")
+			return nil
+		}
+	}
+	for _, g := range m.Groups {
+		for _, member := range m.Proxies[g].All {
+			if member == target {
+				m.screen = screenProxies
+				m.jumpTo(g, target)
+				return nil
+			}
+		}
+	}
+	return m.setNotice(target+" is not in any group", false)
+}
+
+func (m Model) updateRulesKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.rules.filtering {
+		switch msg.Type {
+		case tea.KeyEsc:
+			m.rules.filtering = false
+			m.rules.filter = textInput{}
+		case tea.KeyEnter:
+			m.rules.filtering = false
+			return m, m.jumpToRuleTarget()
+		case tea.KeyUp, tea.KeyCtrlK:
+			m.moveRuleCursor(-1)
+		case tea.KeyDown, tea.KeyCtrlJ:
+			m.moveRuleCursor(1)
+		case tea.KeyCtrlC:
+			return m.quit()
+		default:
+			if m.rules.filter.update(msg) {
+				m.rules.cursor, m.rules.offset = 0, 0
+			}
+		}
+		m.syncRuleCursor()
+		return m, nil
+	}
+
+	if next, cmd, ok := m.updateListKey(msg, (*Model).moveRuleCursor); ok {
+		return next, cmd
+	}
+	switch msg.String() {
+	case "pgup", "ctrl+u":
+		m.moveRuleCursor(-m.listRows())
+	case "pgdown", "ctrl+d":
+		m.moveRuleCursor(m.listRows())
+	case "/":
+		m.rules.filtering = true
+	case "esc":
+		m.rules.filter = textInput{}
+		m.syncRuleCursor()
+	case "enter":
+		return m, m.jumpToRuleTarget()
+	case "r":
+		return m, loadRulesCmd(m.context(), m.Client)
+	}
+	return m, nil
+}
+
+// Column widths of the rules table
+const (
+	ruleIndexWidth   = 5
+	ruleTypeWidth    = 16
+	rulePayloadWidth = 36
+)
+
+func (m Model) rulesView() string {
+	list := m.visibleRules()
+
+	title := headerStyle.Render(fmt.Sprintf(" Rules %d", len(list)))
+	if len(list) != len(m.rules.list) {
+		title += helpStyle.Render(fmt.Sprintf("/%d", len(m.rules.list)))
+	}
+	header := "   " + fmt.Sprintf("%*s", ruleIndexWidth, "#") + " " + fit("Type", ruleTypeWidth) + " " +
+		fit("Payload", rulePayloadWidth) + " Target"
+	lines := []string{title, separatorStyle.Render(header)}
+
+	rows := m.listRows()
+	switch {
+	case m.rules.err != nil:
+		lines = append(lines, errorStyle.Render(fmt.Sprintf("   %v", m.rules.err)))
+	case !m.rules.loaded:
+		lines = append(lines, helpStyle.Render("   Loading rules..."))
+	case len(list) == 0:
+		lines = append(lines, helpStyle.Render("   No rules"))
+	}
+	for i := m.rules.offset; i < len(list) && i < m.rules.offset+rows && m.rules.err == nil; i++ {
+		r := list[i].rule
+		payload := r.Payload
+		if r.Size >= 0 {
+			payload += fmt.Sprintf(" (%d)", r.Size)
+		}
+		row := fmt.Sprintf("%*d", ruleIndexWidth, list[i].index+1) + " " + fit(r.Type, ruleTypeWidth) + " " +
+			fit(payload, rulePayloadWidth) + " " + r.Proxy
+		if i == m.rules.cursor {
+			lines = append(lines, cursorStyle.Render(">  ")+row)
+		} else {
+			lines = append(lines, "   "+normalStyle.Render(row))
+		}
+	}
+
+	for len(lines) < m.bodyHeight()-minHelpRows {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n" + m.rulesHelp()
+}
+
+func (m Model) rulesHelp() string {
+	switch {
+	case m.rules.filtering:
+		return " " + m.rules.filter.view("/") + helpStyle.Render("  [Ent]Go to target [Esc]Clear")
+	case m.notice.text != "":
+		return m.noticeLine()
+	case m.rules.filter.value != "":
+		return helpStyle.Render(fmt.Sprintf(" /%s  [Esc]Clear  [Ent]Go to target  [Tab]Screens  [q]Quit", m.rules.filter.value))
+	}
+	return helpStyle.Render(" [↑k]↑ [↓j]↓  [/]Search  [Ent]Go to target  [r]Reload  [Tab]Screens  [q]Quit")
+}
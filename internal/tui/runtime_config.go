@@ -0,0 +1,202 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+// configField is a row of the config screen. Fields with a key can be
+// changed to one of their choices through PATCH /configs; a dotted key like
+// "tun.enable" patches a nested value.
+type configField struct {
+	label   string
+	get     func(clash.RuntimeConfig) interface{}
+	key     string
+	choices []interface{}
+}
+
+// The choices follow the clash package, like the mode cycle of the header.
+var (
+	boolChoices     = []interface{}{false, true}
+	modeChoices     = stringChoices(clash.Modes...)
+	logLevelChoices = stringChoices(append(clash.LogLevels[:len(clash.LogLevels):len(clash.LogLevels)], "silent")...)
+)
+
+func stringChoices(values ...string) []interface{} {
+	choices := make([]interface{}, len(values))
+	for i, v := range values {
+		choices[i] = v
+	}
+	return choices
+}
+
+var configFields = []configField{
+	{label: "Mixed port", get: func(c clash.RuntimeConfig) interface{} { return c.MixedPort }},
+	{label: "HTTP port", get: func(c clash.RuntimeConfig) interface{} { return c.Port }},
+	{label: "SOCKS port", get: func(c clash.RuntimeConfig) interface{} { return c.SocksPort }},
+	{label: "Redir port", get: func(c clash.RuntimeConfig) interface{} { return c.RedirPort }},
+	{label: "TProxy port", get: func(c clash.RuntimeConfig) interface{} { return c.TProxyPort }},
+	{label: "Allow LAN", key: "allow-lan", choices: boolChoices,
+		get: func(c clash.RuntimeConfig) interface{} { return c.AllowLan }},
+	{label: "Bind address", get: func(c clash.RuntimeConfig) interface{} { return c.BindAddress }},
+	{label: "Mode", key: "mode", choices: modeChoices,
+		get: func(c clash.RuntimeConfig) interface{} { return c.NormalizedMode() }},
+	{label: "Log level", key: "log-level", choices: logLevelChoices,
+		get: func(c clash.RuntimeConfig) interface{} { return c.LogLevel }},
+	{label: "IPv6", key: "ipv6", choices: boolChoices,
+		get: func(c clash.RuntimeConfig) interface{} { return c.IPv6 }},
+	{label: "TUN", key: "tun.enable", choices: boolChoices,
+		get: func(c clash.RuntimeConfig) interface{} { return c.Tun.Enable }},
+	{label: "TUN stack", get: func(c clash.RuntimeConfig) interface{} { return c.Tun.Stack }},
+	{label: "TUN device", get: func(c clash.RuntimeConfig) interface{} { return c.Tun.Device }},
+}
+
+// change builds the PATCH /configs change setting the field to value.
+func (f configField) change(value interface{}) configChange {
+	parts := strings.Split(f.key, ".")
+	patch := map[string]interface{}{parts[len(parts)-1]: value}
+	for i := len(parts) - 2; i >= 0; i-- {
+		patch = map[string]interface{}{parts[i]: patch}
+	}
+	return configChange{
+		desc:    fmt.Sprintf("%s %v", f.key, value),
+		patch:   patch,
+		applied: func(c clash.RuntimeConfig) bool { return f.get(c) == value },
+	}
+}
+
+// configView is the state of the config screen.
+type configView struct {
+	cursor  int
+	editing bool // Whether the confirmation prompt of the field is shown
+	choice  int  // Index of the proposed value in the field's choices
+}
+
+// openConfig reads the configuration again every time its screen is shown.
+func (m *Model) openConfig() tea.Cmd {
+	return loadRuntimeConfigCmd(m.context(), m.Client)
+}
+
+func (m *Model) moveConfigCursor(delta int) {
+	m.configScreen.cursor = max(min(m.configScreen.cursor+delta, len(configFields)-1), 0)
+}
+
+// startConfigEdit proposes the choice after the field's current value.
+func (m *Model) startConfigEdit() {
+	f := configFields[m.configScreen.cursor]
+	if f.key == "" || !m.runtimeLoaded {
+		return
+	}
+	current := f.get(m.runtime)
+	m.configScreen.choice = 0
+	for i, c := range f.choices {
+		if c == current {
+			m.configScreen.choice = (i + 1) % len(f.choices)
+		}
+	}
+	m.configScreen.editing = true
+}
+
+func (m Model) updateConfigKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.configScreen.editing {
+		f := configFields[m.configScreen.cursor]
+		switch msg.String() {
+		case "left", "h":
+			m.configScreen.choice = (m.configScreen.choice + len(f.choices) - 1) % len(f.choices)
+		case "right", "l":
+			m.configScreen.choice = (m.configScreen.choice + 1) % len(f.choices)
+		case "y":
+			m.configScreen.editing = false
+			change := f.change(f.choices[m.configScreen.choice])
+			return m, tea.Batch(m.setNotice("Setting "+change.desc+"...", false),
+				patchConfigCmd(m.context(), m.Client, change))
+		case "ctrl+c":
+			return m.quit()
+		default:
+			m.configScreen.editing = false
+		}
+		return m, nil
+	}
+
+	if next, cmd, ok := m.updateListKey(msg, (*Model).moveConfigCursor); ok {
+		return next, cmd
+	}
+	switch msg.String() {
+	case "enter", " ":
+		m.startConfigEdit()
+	case "r":
+		return m, loadRuntimeConfigCmd(m.context(), m.Client)
+	}
+	return m, nil
+}
+
+const configLabelWidth = 14
+
+// formatConfigValue renders a value of the running configuration.
+func formatConfigValue(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "on"
+		}
+		return "off"
+	case int:
+		if v == 0 {
+			return "off"
+		}
+		return fmt.Sprint(v)
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+func (m Model) configView() string {
+	lines := []string{headerStyle.Render(" Configuration")}
+	switch {
+	case m.runtimeErr != nil && !m.runtimeLoaded:
+		lines = append(lines, errorStyle.Render(fmt.Sprintf("   %v", m.runtimeErr)))
+	case !m.runtimeLoaded:
+		lines = append(lines, helpStyle.Render("   Loading configuration..."))
+	default:
+		// Title and help line; scroll only when the terminal is that small
+		rows := max(m.bodyHeight()-2, 1)
+		offset := max(m.configScreen.cursor-rows+1, 0)
+		for i := offset; i < len(configFields) && i < offset+rows; i++ {
+			f := configFields[i]
+			value := formatConfigValue(f.get(m.runtime))
+			if f.key == "" {
+				value = helpStyle.Render(value)
+			}
+			row := fit(f.label, configLabelWidth) + " " + value
+			if i == m.configScreen.cursor {
+				lines = append(lines, cursorStyle.Render(">  ")+row)
+			} else {
+				lines = append(lines, "   "+row)
+			}
+		}
+	}
+
+	for len(lines) < m.bodyHeight()-minHelpRows {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n") + "\n" + m.configHelp()
+}
+
+func (m Model) configHelp() string {
+	switch {
+	case m.configScreen.editing:
+		f := configFields[m.configScreen.cursor]
+		return pendingMarkStyle.Render(fmt.Sprintf(" Set %s to < %s >?", strings.ToLower(f.label),
+			formatConfigValue(f.choices[m.configScreen.choice]))) + helpStyle.Render("  [←→]Change [y]Apply [n]Cancel")
+	case m.notice.text != "":
+		return m.noticeLine()
+	}
+	return helpStyle.Render(" [↑k]↑ [↓j]↓  [Ent]Change  [r]Reload  [Tab]Screens  [q]Quit")
+}
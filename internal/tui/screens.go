@@ -0,0 +1,42 @@
+package tui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// screen is the page the TUI shows, cycled with Tab and Shift+Tab.
+type screen int
+
+const (
+	screenProxies screen = iota
+	screenConnections
+	screenLogs
+	screenRules
+	screenProviders
+	screenRuleProviders
+	screenConfig
+	screenCount
+)
+
+// cycleScreen moves to the next (or previous) screen, wrapping around.
+func (m *Model) cycleScreen(direction int) tea.Cmd {
+	return m.openScreen((m.screen + screen(direction) + screenCount) % screenCount)
+}
+
+// openScreen shows s and starts whatever keeps it up to date.
+func (m *Model) openScreen(s screen) tea.Cmd {
+	m.screen = s
+	switch s {
+	case screenConnections:
+		return m.openConnections()
+	case screenLogs:
+		return m.openLogs()
+	case screenRules:
+		return m.openRules()
+	case screenProviders:
+		return m.openProviders()
+	case screenRuleProviders:
+		return m.openRuleProviders()
+	case screenConfig:
+		return m.openConfig()
+	}
+	return nil
+}
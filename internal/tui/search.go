@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// matchesFilter reports whether name contains filter, ignoring case.
+func matchesFilter(name, filter string) bool {
+	return strings.Contains(strings.ToLower(name), strings.ToLower(filter))
+}
+
+func (m Model) filter() string {
+	return m.search.value
+}
+
+func (m *Model) startSearch() {
+	m.searching = true
+}
+
+// clearSearch leaves search mode and shows the whole group again, keeping the
+// cursor on the proxy it was on.
+func (m *Model) clearSearch() {
+	m.searching = false
+	m.search = textInput{}
+	m.restoreCursor()
+}
+
+// applyFilter keeps the cursor on its proxy if it still matches, otherwise
+// it moves to the first match.
+func (m *Model) applyFilter() {
+	if !m.restoreCursor() {
+		m.Cursor = 0
+		m.ViewportOffset = 0
+		m.updateLastCursorProxy()
+		m.adjustViewport()
+	}
+}
+
+// jumpMatch moves to the next (or previous) match, wrapping around.
+func (m *Model) jumpMatch(direction int) {
+	if m.CurrentIdx >= len(m.Groups) {
+		return
+	}
+	total := len(m.members(m.Groups[m.CurrentIdx]))
+	if total == 0 {
+		return
+	}
+	m.Cursor = ((m.Cursor+direction)%total + total) % total
+	m.updateLastCursorProxy()
+	m.adjustViewport()
+}
+
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.clearSearch()
+		return m, nil
+	case tea.KeyEnter:
+		m.searching = false
+		return m.selectCursorProxy()
+	case tea.KeyUp, tea.KeyCtrlK:
+		m.moveCursor(-1)
+		return m, nil
+	case tea.KeyDown, tea.KeyCtrlJ:
+		m.moveCursor(1)
+		return m, nil
+	case tea.KeyCtrlC:
+		return m.quit()
+	}
+	if m.search.update(msg) {
+		m.applyFilter()
+	}
+	return m, nil
+}
+
+// searchLine replaces the help line while searching or filtering.
+func (m Model) searchLine() string {
+	matches := 0
+	if m.CurrentIdx < len(m.Groups) {
+		matches = len(m.members(m.Groups[m.CurrentIdx]))
+	}
+	if m.searching {
+		return " " + m.search.view("/") + helpStyle.Render(fmt.Sprintf("  %d matches  [Ent]Select [Esc]Cancel", matches))
+	}
+	return helpStyle.Render(fmt.Sprintf(" /%s  %d matches  [n]Next [N]Prev  [Esc]Clear  [q]Quit", m.filter(), matches))
+}
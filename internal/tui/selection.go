@@ -0,0 +1,189 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
+)
+
+const (
+	confirmAttempts = 5
+	confirmBackoff  = 100 * time.Millisecond
+	noticeDuration  = 4 * time.Second
+)
+
+// pendingSelection is a proxy switch sent to the controller but not yet
+// confirmed. The UI already shows proxy as active and falls back to previous
+// if the switch fails.
+type pendingSelection struct {
+	id       int
+	proxy    string
+	previous string
+}
+
+type selectResultMsg struct {
+	id     int
+	group  string
+	proxy  string
+	loaded *proxiesLoadedMsg // Controller state confirming the switch
+	err    error
+}
+
+// selectProxyCmd switches the group and confirms the switch by reading the
+// group back until the controller reports the new proxy as active.
+func selectProxyCmd(ctx context.Context, client clash.Backend, id int, group, proxy string) tea.Cmd {
+	return func() tea.Msg {
+		msg := selectResultMsg{id: id, group: group, proxy: proxy}
+		if err := client.SelectProxy(ctx, group, proxy); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			msg.err = err
+			return msg
+		}
+
+		backoff := confirmBackoff
+		for attempt := 1; ; attempt++ {
+			resp, err := client.GetProxies(ctx)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				msg.err = err
+				return msg
+			}
+			now := resp.Proxies[group].Now
+			if now == proxy {
+				loaded := newProxiesLoadedMsg(resp.Proxies)
+				msg.loaded = &loaded
+				return msg
+			}
+			if attempt == confirmAttempts {
+				msg.err = fmt.Errorf("controller still reports %s as active", now)
+				return msg
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// selectCursorProxy makes the proxy under the cursor the active one of the
+// current group. The list is updated right away and rolled back if the
+// controller rejects the switch.
+func (m Model) selectCursorProxy() (tea.Model, tea.Cmd) {
+	group, selectedProxy, ok := m.cursorProxy()
+	if !ok {
+		return m, nil
+	}
+	proxy := m.Proxies[group]
+	if !isSelectable(proxy) {
+		// Nothing to pick here, but a nested group can still be opened
+		if m.drillIn() {
+			return m, nil
+		}
+		return m, m.setNotice(fmt.Sprintf("%s is a %s group and uses its members itself", group, proxy.Type), false)
+	}
+	if proxy.Now == selectedProxy {
+		return m, nil
+	}
+
+	m.selectSeq++
+	if m.pending == nil {
+		m.pending = make(map[string]pendingSelection)
+	}
+	previous := proxy.Now
+	if p, ok := m.pending[group]; ok {
+		// Roll back to what the controller had before the first switch
+		previous = p.previous
+	}
+	m.pending[group] = pendingSelection{id: m.selectSeq, proxy: selectedProxy, previous: previous}
+	m.setNow(group, selectedProxy)
+	return m, selectProxyCmd(m.context(), m.Client, m.selectSeq, group, selectedProxy)
+}
+
+func (m Model) handleSelectResult(msg selectResultMsg) (tea.Model, tea.Cmd) {
+	p, ok := m.pending[msg.group]
+	if !ok || p.id != msg.id {
+		// Superseded by a newer switch of the same group
+		return m, nil
+	}
+	delete(m.pending, msg.group)
+
+	if msg.err != nil {
+		m.setNow(msg.group, p.previous)
+		return m, m.setNotice(fmt.Sprintf("Failed to select %s: %v", msg.proxy, msg.err), true)
+	}
+	if !m.closesOnSwitch(msg.group) {
+		return m.Update(*msg.loaded)
+	}
+	updated, cmd := m.Update(*msg.loaded)
+	return updated, tea.Batch(cmd, closeStaleConnectionsCmd(m.context(), m.Client, msg.group, msg.proxy))
+}
+
+// setNow marks proxy as the active member of group. The proxy map is copied
+// so earlier models (and the backend's data) are left untouched.
+func (m *Model) setNow(group, proxy string) {
+	g, ok := m.Proxies[group]
+	if !ok {
+		return
+	}
+	proxies := make(map[string]clash.Proxy, len(m.Proxies))
+	for name, p := range m.Proxies {
+		proxies[name] = p
+	}
+	g.Now = proxy
+	proxies[group] = g
+	m.Proxies = proxies
+}
+
+// applyPending re-applies switches in flight on top of freshly loaded data,
+// so a reload racing with a switch does not flip the list back.
+func (m *Model) applyPending() {
+	for group, p := range m.pending {
+		if _, ok := m.Proxies[group]; ok {
+			m.setNow(group, p.proxy)
+		}
+	}
+}
+
+func (m Model) isPending(group string) bool {
+	_, ok := m.pending[group]
+	return ok
+}
+
+type clearNoticeMsg struct {
+	id int
+}
+
+// notice is a short message shown in place of the help line.
+type notice struct {
+	id   int
+	text string
+	err  bool
+}
+
+// setNotice shows text in the status line and returns the command that
+// clears it again after a while.
+func (m *Model) setNotice(text string, isErr bool) tea.Cmd {
+	id := m.notice.id + 1
+	m.notice = notice{id: id, text: text, err: isErr}
+	return tea.Tick(noticeDuration, func(time.Time) tea.Msg {
+		return clearNoticeMsg{id: id}
+	})
+}
+
+func (m Model) noticeLine() string {
+	if m.notice.err {
+		return errorStyle.Render(" " + m.notice.text)
+	}
+	return noticeStyle.Render(" " + m.notice.text)
+}
@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"sort"
+)
+
+// sortMode is the order in which the proxies of a group are listed.
+type sortMode int
+
+const (
+	sortOriginal sortMode = iota // The order reported by the controller
+	sortName                     // Natural name order, "Proxy-9" before "Proxy-10"
+	sortLatency                  // Lowest latency first, dead and untested last
+	sortAlive                    // Alive proxies first, otherwise original order
+	sortModeCount
+)
+
+func (s sortMode) String() string {
+	switch s {
+	case sortName:
+		return "name"
+	case sortLatency:
+		return "latency"
+	case sortAlive:
+		return "alive"
+	default:
+		return "original"
+	}
+}
+
+func parseSortMode(s string) sortMode {
+	for mode := sortOriginal; mode < sortModeCount; mode++ {
+		if mode.String() == s {
+			return mode
+		}
+	}
+	return sortOriginal
+}
+
+func (m Model) sortModeOf(group string) sortMode {
+	if m.Proxies[group].Type == "Relay" {
+		// The members of a relay are a chain; their order is the point
+		return sortOriginal
+	}
+	if mode, ok := m.groupSort[group]; ok {
+		return mode
+	}
+	return parseSortMode(m.Config.UI.Sort)
+}
+
+// cycleSort switches the current group to the next sort mode, keeping the
+// cursor on the proxy it was on.
+func (m *Model) cycleSort() {
+	if m.CurrentIdx >= len(m.Groups) {
+		return
+	}
+	group := m.Groups[m.CurrentIdx]
+	if m.groupSort == nil {
+		m.groupSort = make(map[string]sortMode)
+	}
+	m.groupSort[group] = (m.sortModeOf(group) + 1) % sortModeCount
+	m.restoreCursor()
+}
+
+// members returns the proxies of group in the order they are listed,
+// narrowed down by the search filter for the current group.
+func (m Model) members(group string) []string {
+	proxy, ok := m.Proxies[group]
+	if !ok {
+		return nil
+	}
+	filtering := m.filter() != "" && m.CurrentIdx < len(m.Groups) && m.Groups[m.CurrentIdx] == group
+	mode := m.sortModeOf(group)
+	if mode == sortOriginal && !filtering {
+		return proxy.All
+	}
+
+	names := make([]string, 0, len(proxy.All))
+	for _, name := range proxy.All {
+		if !filtering || matchesFilter(name, m.filter()) {
+			names = append(names, name)
+		}
+	}
+	switch mode {
+	case sortName:
+		sort.SliceStable(names, func(i, j int) bool {
+			return naturalLess(names[i], names[j])
+		})
+	case sortLatency:
+		sort.SliceStable(names, func(i, j int) bool {
+			return m.latencyRank(names[i]) < m.latencyRank(names[j])
+		})
+	case sortAlive:
+		sort.SliceStable(names, func(i, j int) bool {
+			return m.aliveRank(names[i]) < m.aliveRank(names[j])
+		})
+	}
+	return names
+}
+
+// latencyRank orders by delay, with untested and dead proxies after every
+// alive one.
+func (m Model) latencyRank(name string) int {
+	l, ok := m.latencyOf(name)
+	switch {
+	case !ok:
+		return 1 << 30
+	case l.failed || l.dead():
+		return 1<<30 + 1
+	default:
+		return l.delay
+	}
+}
+
+func (m Model) aliveRank(name string) int {
+	l, ok := m.latencyOf(name)
+	switch {
+	case !ok:
+		return 1
+	case l.failed || l.dead():
+		return 2
+	default:
+		return 0
+	}
+}
+
+// naturalLess compares strings treating runs of digits as numbers.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da > 0 && db > 0 {
+			na, nb := trimZeros(a[:da]), trimZeros(b[:db])
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[da:], b[db:]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+func trimZeros(s string) string {
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
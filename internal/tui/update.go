@@ -1,7 +1,10 @@
 package tui
 
 import (
+	"fmt"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
 )
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -11,138 +14,247 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.Err = msg
 		return m, nil
 
+	case delayResultMsg:
+		if m.Delays == nil {
+			m.Delays = make(map[string]clash.DelayResult)
+		}
+		m.Delays[msg.Proxy] = clash.DelayResult(msg)
+		m.restoreCursor()
+		return m, nil
+
+	case groupTestResultMsg:
+		if msg.id != m.groupTest.id || !m.groupTest.running() {
+			return m, nil
+		}
+		if m.Delays == nil {
+			m.Delays = make(map[string]clash.DelayResult)
+		}
+		m.Delays[msg.result.Proxy] = msg.result
+		m.groupTest.done++
+		m.restoreCursor()
+		return m, waitGroupTestCmd(m.groupTest.id, m.groupTest.results)
+
+	case selectResultMsg:
+		return m.handleSelectResult(msg)
+
+	case clearNoticeMsg:
+		if msg.id == m.notice.id {
+			m.notice = notice{id: m.notice.id}
+		}
+		return m, nil
+
+	case groupTestDoneMsg:
+		if msg.id == m.groupTest.id && m.groupTest.running() {
+			m.groupTest.cancel()
+			m.groupTest = groupTest{id: m.groupTest.id}
+		}
+		return m, nil
+
+	case connectionsLoadedMsg, connectionsTickMsg, connectionsClosedMsg:
+		return m.updateConnectionsMsg(msg)
+
+	case staleConnectionsClosedMsg:
+		if msg.err != nil {
+			return m, m.setNotice(fmt.Sprintf("Failed to close connections of %s: %v", msg.group, msg.err), true)
+		}
+		return m, m.setNotice(fmt.Sprintf("Switched %s, closed %d connection%s", msg.group, msg.count, plural(msg.count)), false)
+
 	case tea.WindowSizeMsg:
 		m.Height = msg.Height
-		m.adjustViewport()
+		m.Width = msg.Width
+		m.syncLayout()
+		return m, nil
+
+	case trafficMsg:
+		return m, m.addTrafficSample(msg)
+
+	case trafficStaleMsg:
+		// Nothing to update, the meter shows "--" when rendered past
+		// trafficStaleAfter
+		return m, nil
+
+	case logEntryMsg, logsSavedMsg:
+		return m.updateLogsMsg(msg)
+
+	case providersLoadedMsg, providerActionMsg:
+		return m.updateProvidersMsg(msg)
+
+	case ruleProvidersLoadedMsg, ruleProviderRefreshedMsg:
+		return m.updateRuleProvidersMsg(msg)
+
+	case runtimeConfigLoadedMsg, configPatchedMsg:
+		return m.updateRuntimeConfigMsg(msg)
+
+	case rulesLoadedMsg:
+		m.rules.loaded = true
+		m.rules.err = msg.err
+		if msg.err == nil {
+			m.rules.list = msg.resp.Rules
+		}
+		m.syncRuleCursor()
 		return m, nil
 
 	case proxiesLoadedMsg:
 		m.Loading = false
+		m.Err = nil
 		m.Proxies = msg.proxies
-		m.Groups = msg.groups
-		if m.CurrentIdx >= len(m.Groups) {
-			m.CurrentIdx = 0
-		}
-		if len(m.Groups) > 0 && m.CurrentIdx < len(m.Groups) {
-			if proxy, ok := m.Proxies[m.Groups[m.CurrentIdx]]; ok {
-				// Try to restore cursor position based on the proxy name we were on
-				cursorFound := false
-				if m.lastCursorProxy != "" {
-					for i, p := range proxy.All {
-						if p == m.lastCursorProxy {
-							m.Cursor = i
-							cursorFound = true
-							break
-						}
-					}
-				}
-				// Fall back to active proxy if:
-				// 1. We couldn't find the last cursor proxy by name
-				// 2. OR this is the first load (lastCursorProxy is empty)
-				if !cursorFound {
-					for i, p := range proxy.All {
-						if p == proxy.Now {
-							m.Cursor = i
-							break
-						}
-					}
-				}
-				// Update lastCursorProxy to match the current cursor position
-				m.updateLastCursorProxy()
-			}
+		m.setGroups(msg.groups)
+		m.restoreProfileState()
+		m.applyPending()
+		// Try to restore cursor position based on the proxy name we were on,
+		// falling back to the active proxy if it is gone (or on first load)
+		if !m.restoreCursor() {
+			m.cursorToActive()
 		}
+		m.updateLastCursorProxy()
+		// Subscribe to the traffic stream and read the running configuration
+		// once the controller is reachable. The traffic meter takes its
+		// header row right away, so the viewport is placed after that.
+		cmd := tea.Batch(m.startTraffic(), m.loadRuntimeConfigOnce())
 		m.adjustViewport()
-		return m, nil
+		return m, cmd
 
 	case tea.KeyMsg:
+		switch m.screen {
+		case screenConnections:
+			return m.updateConnectionsKey(msg)
+		case screenLogs:
+			return m.updateLogsKey(msg)
+		case screenRules:
+			return m.updateRulesKey(msg)
+		case screenProviders:
+			return m.updateProvidersKey(msg)
+		case screenRuleProviders:
+			return m.updateRuleProvidersKey(msg)
+		case screenConfig:
+			return m.updateConfigKey(msg)
+		}
+		// The picker works even while the controller is unreachable
+		if m.profiles.picking {
+			return m.updateProfilePicker(msg)
+		}
+		if msg.String() == "P" && len(m.profiles.list) > 1 {
+			m.openProfilePicker()
+			return m, nil
+		}
 		if m.Loading {
+			// Only allow giving up on (or restarting) a load in flight
+			switch msg.String() {
+			case "q", "ctrl+c":
+				return m.quit()
+			case "r":
+				return m, LoadProxiesCmd(m.newLoadContext(), m.Client)
+			}
 			return m, nil
 		}
+		if m.finder.open {
+			return m.updateFinder(msg)
+		}
+		if m.searching {
+			return m.updateSearch(msg)
+		}
 
 		switch msg.Type {
 		case tea.KeyUp, tea.KeyCtrlK:
-			if m.CurrentIdx < len(m.Groups) {
-				group := m.Groups[m.CurrentIdx]
-				if proxy, ok := m.Proxies[group]; ok && len(proxy.All) > 0 {
-					if m.Cursor > 0 {
-						m.Cursor--
-						m.updateLastCursorProxy()
-						m.adjustViewport()
-					}
-				}
-			}
+			m.moveCursor(-1)
 			return m, nil
 
 		case tea.KeyDown, tea.KeyCtrlJ:
-			if m.CurrentIdx < len(m.Groups) {
-				group := m.Groups[m.CurrentIdx]
-				if proxy, ok := m.Proxies[group]; ok && len(proxy.All) > 0 {
-					if m.Cursor < len(proxy.All)-1 {
-						m.Cursor++
-						m.updateLastCursorProxy()
-						m.adjustViewport()
-					}
-				}
-			}
+			m.moveCursor(1)
 			return m, nil
 
 		case tea.KeyLeft:
+			if m.drillOut() {
+				return m, nil
+			}
 			return m.navigateGroup(-1)
 
 		case tea.KeyRight:
+			if m.drillIn() {
+				return m, nil
+			}
 			return m.navigateGroup(1)
 
+		case tea.KeyBackspace:
+			m.drillOut()
+			return m, nil
+
 		case tea.KeyEnter:
-			if m.CurrentIdx < len(m.Groups) {
-				group := m.Groups[m.CurrentIdx]
-				if proxy, ok := m.Proxies[group]; ok && m.Cursor < len(proxy.All) {
-					selectedProxy := proxy.All[m.Cursor]
-					if err := m.Client.SelectProxy(group, selectedProxy); err != nil {
-						m.Err = err
-						return m, nil
-					}
-					return m, loadProxiesWithDelayCmd(m.Client)
-				}
+			return m.selectCursorProxy()
+
+		case tea.KeyEsc:
+			if m.groupTest.running() {
+				return m, m.toggleGroupTest()
+			}
+			if m.filter() != "" {
+				m.clearSearch()
+				return m, nil
 			}
+			if m.detail && !m.detailSideBySide() {
+				m.detail = false
+				return m, nil
+			}
+			m.drillOut()
+			return m, nil
+
+		case tea.KeyCtrlP:
+			m.openFinder()
 			return m, nil
 
+		case tea.KeyTab:
+			return m, m.cycleScreen(1)
+
+		case tea.KeyShiftTab:
+			return m, m.cycleScreen(-1)
+
 		case tea.KeyCtrlC:
-			return m, tea.Quit
+			return m.quit()
 		}
 
 		switch msg.String() {
 		case "q":
-			return m, tea.Quit
+			return m.quit()
 		case "r":
 			m.Loading = true
-			return m, LoadProxiesCmd(m.Client)
+			return m, tea.Batch(LoadProxiesCmd(m.newLoadContext(), m.Client), loadRuntimeConfigCmd(m.context(), m.Client))
+		case "m":
+			return m, m.cycleMode()
+		case "i":
+			m.detail = !m.detail
+			return m, nil
+		case "d":
+			return m, m.testCursorDelay()
+		case "t":
+			return m, m.toggleGroupTest()
+		case "s":
+			m.cycleSort()
+			return m, nil
+		case "c":
+			return m, m.toggleCloseOnSwitch()
+		case "/":
+			m.startSearch()
+			return m, nil
+		case "n":
+			m.jumpMatch(1)
+			return m, nil
+		case "N":
+			m.jumpMatch(-1)
+			return m, nil
 		case "h":
+			if m.drillOut() {
+				return m, nil
+			}
 			return m.navigateGroup(-1)
 		case "l":
+			if m.drillIn() {
+				return m, nil
+			}
 			return m.navigateGroup(1)
 		case "k":
-			if m.CurrentIdx < len(m.Groups) {
-				group := m.Groups[m.CurrentIdx]
-				if proxy, ok := m.Proxies[group]; ok && len(proxy.All) > 0 {
-					if m.Cursor > 0 {
-						m.Cursor--
-						m.updateLastCursorProxy()
-						m.adjustViewport()
-					}
-				}
-			}
+			m.moveCursor(-1)
 			return m, nil
 		case "j":
-			if m.CurrentIdx < len(m.Groups) {
-				group := m.Groups[m.CurrentIdx]
-				if proxy, ok := m.Proxies[group]; ok && len(proxy.All) > 0 {
-					if m.Cursor < len(proxy.All)-1 {
-						m.Cursor++
-						m.updateLastCursorProxy()
-						m.adjustViewport()
-					}
-				}
-			}
+			m.moveCursor(1)
 			return m, nil
 		}
 	}
@@ -153,15 +265,9 @@ func (m *Model) navigateGroup(direction int) (tea.Model, tea.Cmd) {
 	newIdx := m.CurrentIdx + direction
 	if newIdx >= 0 && newIdx < len(m.Groups) {
 		m.CurrentIdx = newIdx
-		group := m.Groups[m.CurrentIdx]
-		if proxy, ok := m.Proxies[group]; ok {
-			for i, p := range proxy.All {
-				if p == proxy.Now {
-					m.Cursor = i
-					m.updateLastCursorProxy()
-					break
-				}
-			}
+		m.search = textInput{}
+		if _, ok := m.Proxies[m.Groups[m.CurrentIdx]]; ok {
+			m.cursorToActive()
 		} else {
 			m.Cursor = 0
 			m.lastCursorProxy = ""
@@ -172,28 +278,94 @@ func (m *Model) navigateGroup(direction int) (tea.Model, tea.Cmd) {
 	return *m, nil
 }
 
-func (m *Model) updateLastCursorProxy() {
-	if m.CurrentIdx < len(m.Groups) {
-		group := m.Groups[m.CurrentIdx]
-		if proxy, ok := m.Proxies[group]; ok && m.Cursor < len(proxy.All) {
-			m.lastCursorProxy = proxy.All[m.Cursor]
+// cursorProxy returns the current group and the proxy under the cursor.
+func (m Model) cursorProxy() (group, proxy string, ok bool) {
+	if m.CurrentIdx >= len(m.Groups) {
+		return "", "", false
+	}
+	group = m.Groups[m.CurrentIdx]
+	members := m.members(group)
+	if m.Cursor < 0 || m.Cursor >= len(members) {
+		return "", "", false
+	}
+	return group, members[m.Cursor], true
+}
+
+func (m *Model) moveCursor(delta int) {
+	if m.CurrentIdx >= len(m.Groups) {
+		return
+	}
+	newCursor := m.Cursor + delta
+	if newCursor < 0 || newCursor >= len(m.members(m.Groups[m.CurrentIdx])) {
+		return
+	}
+	m.Cursor = newCursor
+	m.updateLastCursorProxy()
+	m.adjustViewport()
+}
+
+// placeCursor moves the cursor onto the named proxy of the current group.
+func (m *Model) placeCursor(name string) bool {
+	if name == "" || m.CurrentIdx >= len(m.Groups) {
+		return false
+	}
+	for i, p := range m.members(m.Groups[m.CurrentIdx]) {
+		if p == name {
+			m.Cursor = i
+			m.updateLastCursorProxy()
+			m.adjustViewport()
+			return true
 		}
 	}
+	return false
+}
+
+// restoreCursor puts the cursor back on the proxy it was on, which may have
+// moved after a reload or a re-sort.
+func (m *Model) restoreCursor() bool {
+	return m.placeCursor(m.lastCursorProxy)
+}
+
+// cursorToActive moves the cursor onto the active proxy of the current group.
+func (m *Model) cursorToActive() {
+	if m.CurrentIdx >= len(m.Groups) {
+		return
+	}
+	if proxy, ok := m.Proxies[m.Groups[m.CurrentIdx]]; ok {
+		m.placeCursor(proxy.Now)
+	}
+}
+
+func (m *Model) updateLastCursorProxy() {
+	if _, proxy, ok := m.cursorProxy(); ok {
+		m.lastCursorProxy = proxy
+	}
+}
+
+// syncLayout fits the cursors and scroll positions of every screen to the
+// room left for the body, after the terminal or the header changed size.
+func (m *Model) syncLayout() {
+	m.adjustViewport()
+	m.syncConnectionCursor()
+	m.clampLogScroll()
+	m.syncRuleCursor()
+	m.syncProviderCursor()
+	m.syncRuleProviderCursor()
 }
 
 func (m *Model) adjustViewport() {
-	if len(m.Groups) == 0 {
+	if len(m.Groups) == 0 || m.CurrentIdx >= len(m.Groups) {
 		return
 	}
 	group := m.Groups[m.CurrentIdx]
-	proxy, ok := m.Proxies[group]
-	if !ok {
+	if _, ok := m.Proxies[group]; !ok {
 		return
 	}
+	total := len(m.members(group))
 
 	// Calculate max visible proxies based on terminal height
 	// Footer takes: help (1 row)
-	availableRows := m.Height - len(m.Groups) - minHelpRows
+	availableRows := m.bodyHeight() - len(m.Groups) - minHelpRows
 	if availableRows < 1 {
 		availableRows = 1
 	}
@@ -209,7 +381,7 @@ func (m *Model) adjustViewport() {
 		m.ViewportOffset = 0
 	}
 
-	maxOffset := len(proxy.All) - visibleCount
+	maxOffset := total - visibleCount
 	if maxOffset < 0 {
 		maxOffset = 0
 	}
@@ -3,11 +3,43 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/clash"
 )
 
 func (m Model) View() string {
+	var body string
+	switch m.screen {
+	case screenConnections:
+		body = m.connectionsView()
+	case screenLogs:
+		body = m.logsView()
+	case screenRules:
+		body = m.rulesView()
+	case screenProviders:
+		body = m.providersView()
+	case screenRuleProviders:
+		body = m.ruleProvidersView()
+	case screenConfig:
+		body = m.configView()
+	default:
+		body = m.proxiesView()
+		if m.detail {
+			body = m.withDetail(body)
+		}
+		if m.profiles.picking {
+			body = m.profilePickerView()
+		}
+	}
+	if header := m.headerLine(); header != "" {
+		return header + "\n" + body
+	}
+	return body
+}
+
+func (m Model) proxiesView() string {
 	if m.Loading {
 		return separatorStyle.Render("═══════════════════════════════════════") + "\n" +
 			headerStyle.Render("  Loading proxies...")
@@ -17,7 +49,11 @@ func (m Model) View() string {
 		return separatorStyle.Render("═══════════════════════════════════════") + "\n" +
 			headerStyle.Render("  Error") + "\n" +
 			fmt.Sprintf("  %v\n", m.Err) +
-			helpStyle.Render("  Press [r] retry, [q] quit")
+			helpStyle.Render("  Press [r] retry, "+m.profileHint()+"[q] quit")
+	}
+
+	if m.finder.open {
+		return m.finderView()
 	}
 
 	if len(m.Groups) == 0 {
@@ -31,8 +67,8 @@ func (m Model) View() string {
 	for _, group := range m.Groups {
 		proxy, ok := m.Proxies[group]
 		groupWithType := group
-		if ok && proxy.Type != "" {
-			groupWithType = group + " (" + proxy.Type + ")"
+		if ok {
+			groupWithType = m.groupLabel(group, proxy)
 		}
 		groupWidth := lipgloss.Width(groupWithType)
 		if groupWidth > maxGroupWidth {
@@ -46,15 +82,15 @@ func (m Model) View() string {
 	proxyLines := 0
 	for i, group := range m.Groups {
 		if i == m.CurrentIdx {
-			if proxy, ok := m.Proxies[group]; ok {
+			if _, ok := m.Proxies[group]; ok {
 				// Calculate how many proxies we can show
-				availableRows := m.Height - len(m.Groups) - minHelpRows
+				availableRows := m.bodyHeight() - len(m.Groups) - minHelpRows
 				if availableRows < 1 {
 					availableRows = 1
 				}
 				visibleCount := availableRows
-				if len(proxy.All) < visibleCount {
-					proxyLines = len(proxy.All)
+				if total := len(m.members(group)); total < visibleCount {
+					proxyLines = total
 				} else {
 					proxyLines = visibleCount
 				}
@@ -65,8 +101,8 @@ func (m Model) View() string {
 	// Calculate padding after selected group's proxies to push remaining groups down
 	// This ensures bottom group stays near help line
 	// Total content = len(m.Groups) groups + proxyLines + 1 help line
-	// Available padding = m.Height - len(m.Groups) - proxyLines - 1
-	paddingAfterSelected := m.Height - len(m.Groups) - proxyLines - 1
+	// Available padding = m.bodyHeight() - len(m.Groups) - proxyLines - 1
+	paddingAfterSelected := m.bodyHeight() - len(m.Groups) - proxyLines - 1
 	if paddingAfterSelected < 0 {
 		paddingAfterSelected = 0
 	}
@@ -78,10 +114,7 @@ func (m Model) View() string {
 		}
 
 		// Pad group name to uniform display width with 3 spaces on each side
-		groupWithType := group
-		if proxy.Type != "" {
-			groupWithType = group + " (" + proxy.Type + ")"
-		}
+		groupWithType := m.groupLabel(group, proxy)
 		currentWidth := lipgloss.Width(groupWithType)
 		paddedGroup := "   " + groupWithType + strings.Repeat(" ", maxGroupWidth-currentWidth) + "   "
 
@@ -91,44 +124,79 @@ func (m Model) View() string {
 		} else {
 			groupLabel = normalGroupStyle.Render(paddedGroup)
 		}
+		groupLabel += m.chainText(group)
+		if crumbs := m.breadcrumb(); i == m.CurrentIdx && crumbs != "" {
+			groupLabel += helpStyle.Render(" " + crumbs)
+		}
+		if mode := m.sortModeOf(group); i == m.CurrentIdx && mode != sortOriginal {
+			groupLabel += helpStyle.Render(" sorted by " + mode.String())
+		}
+		if hint := groupHint(proxy); i == m.CurrentIdx && hint != "" {
+			groupLabel += helpStyle.Render(" " + hint)
+		}
+		if i == m.CurrentIdx && m.closesOnSwitch(group) {
+			groupLabel += helpStyle.Render(" closes conns on switch")
+		}
+		if m.groupTest.running() && m.groupTest.group == group {
+			groupLabel += helpStyle.Render(fmt.Sprintf(" testing %d/%d", m.groupTest.done, m.groupTest.total))
+		}
 		s += groupLabel + "\n"
 
 		if i == m.CurrentIdx {
 			// Calculate how many proxies we can show
 			// Footer takes: help (1 row)
-			availableRows := m.Height - len(m.Groups) - minHelpRows
+			availableRows := m.bodyHeight() - len(m.Groups) - minHelpRows
 			if availableRows < 1 {
 				availableRows = 1
 			}
 			visibleCount := availableRows
 
-			visibleProxies := proxy.All
-			if len(proxy.All) > visibleCount {
+			members := m.members(group)
+			visibleProxies := members
+			if len(members) > visibleCount {
 				startIdx := m.ViewportOffset
 				if startIdx < 0 {
 					startIdx = 0
 				}
 				endIdx := startIdx + visibleCount
-				if endIdx > len(proxy.All) {
-					endIdx = len(proxy.All)
+				if endIdx > len(members) {
+					endIdx = len(members)
 				}
-				visibleProxies = proxy.All[startIdx:endIdx]
+				visibleProxies = members[startIdx:endIdx]
 			}
 
+			// Right-align the latency column behind the longest proxy name
+			showLatency := m.hasLatency(proxy.All)
+			nameWidth := 0
+			for _, p := range proxy.All {
+				nameWidth = max(nameWidth, lipgloss.Width(p)+lipgloss.Width(m.memberMarks(p)))
+			}
+			now := time.Now()
+			pending := m.isPending(group)
+
 			for j, p := range visibleProxies {
 				actualIdx := j + m.ViewportOffset
 				var line string
-				if actualIdx == m.Cursor && p == proxy.Now {
+				if actualIdx == m.Cursor && p == proxy.Now && pending {
+					line = cursorStyle.Render(">") + pendingMarkStyle.Render("~") + " " + activeProxyStyle.Render(p)
+				} else if actualIdx == m.Cursor && p == proxy.Now {
 					line = cursorStyle.Render(">> ") + activeProxyStyle.Render(p)
 				} else if actualIdx == m.Cursor {
 					line = cursorStyle.Render(">  ") + p
+				} else if p == proxy.Now && pending {
+					line = " " + pendingMarkStyle.Render("~") + " " + activeProxyStyle.Render(p)
 				} else if p == proxy.Now {
 					line = " " + activeProxyMarkStyle.Render(">") + " " + activeProxyStyle.Render(p)
 				} else {
 					line = "   " + normalStyle.Render(p)
 				}
-				if actualIdx == m.Cursor && len(proxy.All) > visibleCount {
-					line += helpStyle.Render(fmt.Sprintf(" (%d/%d)", m.Cursor+1, len(proxy.All)))
+				mark := m.memberMarks(p)
+				line += mark
+				if showLatency {
+					line += strings.Repeat(" ", nameWidth-lipgloss.Width(p)-lipgloss.Width(mark)) + " " + m.latencyColumn(p, now)
+				}
+				if actualIdx == m.Cursor && len(members) > visibleCount {
+					line += helpStyle.Render(fmt.Sprintf(" (%d/%d)", m.Cursor+1, len(members)))
 				}
 				s += line + "\n"
 			}
@@ -150,7 +218,105 @@ func (m Model) View() string {
 	}
 
 	// Add help text at bottom
-	s += helpStyle.Render(" [←h]Prev [→l]Next  [↑k]↑ [↓j]↓  [Ent]Select  [r]Reload  [q]Quit")
+	if m.searching {
+		return s + m.searchLine()
+	}
+	if m.notice.text != "" {
+		return s + m.noticeLine()
+	}
+	if m.filter() != "" {
+		return s + m.searchLine()
+	}
+	s += m.helpLine(
+		[]helpKey{{"[←h]Prev [→l]Next", 3}},
+		[]helpKey{{"[↑k]↑", 0}, {"[↓j]↓", 0}},
+		[]helpKey{{"[Ent]Select", 0}},
+		[]helpKey{{"[d]Delay", 2}, {"[t]Test all", 4}, {"[s]Sort", 7}, {"[/]Search", 1}, {"[i]Info", 6}, {"[m]Mode", 8}},
+		[]helpKey{{"[r]Reload", 5}},
+		[]helpKey{{"[Tab]Screens", 0}},
+		[]helpKey{{"[q]Quit", 0}},
+	)
 
 	return s
 }
+
+// helpKey is a key hint of a help line.
+type helpKey struct {
+	text string
+	drop int // Keys ranked higher are left out first; 0 is always shown
+}
+
+// helpLine renders groups of key hints, leaving out the least important
+// keys until the line fits the terminal.
+func (m Model) helpLine(groups ...[]helpKey) string {
+	render := func() string {
+		var parts []string
+		for _, g := range groups {
+			texts := make([]string, len(g))
+			for i, k := range g {
+				texts[i] = k.text
+			}
+			if len(texts) > 0 {
+				parts = append(parts, strings.Join(texts, " "))
+			}
+		}
+		return " " + strings.Join(parts, "  ")
+	}
+
+	groups = append([][]helpKey(nil), groups...)
+	line := render()
+	for m.Width > 0 && lipgloss.Width(line) > m.Width {
+		// Drop the last of the keys ranked highest
+		gi, ki := -1, -1
+		for i, g := range groups {
+			for j, k := range g {
+				if k.drop > 0 && (gi < 0 || k.drop >= groups[gi][ki].drop) {
+					gi, ki = i, j
+				}
+			}
+		}
+		if gi < 0 {
+			return helpStyle.Render(lipgloss.NewStyle().MaxWidth(m.Width).Render(line))
+		}
+		g := groups[gi]
+		groups[gi] = append(g[:ki:ki], g[ki+1:]...)
+		line = render()
+	}
+	return helpStyle.Render(line)
+}
+
+// memberMarks flags a member that is a group itself and can be opened, or
+// else a node found dead and whether it relays UDP.
+func (m Model) memberMarks(name string) string {
+	if m.groupIndex(name) >= 0 {
+		return helpStyle.Render(" ▸")
+	}
+	var marks string
+	if m.isDead(name) {
+		marks += latencyDeadStyle.Render(" ✗")
+	}
+	if m.Proxies[name].UDP {
+		marks += helpStyle.Render(" udp")
+	}
+	return marks
+}
+
+// groupHint explains how a read-only group uses its members.
+func groupHint(proxy clash.Proxy) string {
+	switch proxy.Type {
+	case "LoadBalance":
+		return "read-only, balances across members"
+	case "Relay":
+		return "read-only, chains members in order"
+	}
+	return ""
+}
+
+// groupLabel returns the group name, followed by its type unless disabled in
+// the UI config.
+func (m Model) groupLabel(group string, proxy clash.Proxy) string {
+	if !m.Config.UI.HideGroupType && proxy.Type != "" {
+		return group + " (" + proxy.Type + ")"
+	}
+	return group
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/wallacegibbon/proxy-controller-tui/internal/config"
 	"github.com/wallacegibbon/proxy-controller-tui/internal/tui"
 )
 
@@ -15,11 +17,21 @@ func main() {
 		}
 	}()
 
-	p := tea.NewProgram(
-		tui.InitialModel(),
-		tea.WithAltScreen(),
-		tea.WithMouseCellMotion(),
-	)
+	cfg, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	opts := []tea.ProgramOption{tea.WithMouseCellMotion()}
+	if cfg.UI.AltScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+
+	p := tea.NewProgram(tui.InitialModel(cfg), opts...)
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v", err)
 		os.Exit(1)